@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestRunFormat_Integration tests the complete argument handling flow
@@ -590,6 +592,48 @@ func TestRunFind_Integration(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name:        "find with context lines",
+			args:        []string{"-C", "1", "peace"},
+			workDir:     tempDir,
+			expectError: false,
+			checkOutput: func(output string) error {
+				if !strings.Contains(output, "match2.go:1:6: world peace") {
+					return fmt.Errorf("expected match line in output, got: %s", output)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "find with max count",
+			args:        []string{"--max-count", "1", "hello"},
+			workDir:     tempDir,
+			expectError: false,
+			checkOutput: func(output string) error {
+				if lines := strings.Count(output, "\n"); lines != 1 {
+					return fmt.Errorf("expected exactly 1 result, got %d: %s", lines, output)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "find with json output",
+			args:        []string{"--json", "peace"},
+			workDir:     tempDir,
+			expectError: false,
+			checkOutput: func(output string) error {
+				if !strings.Contains(output, `{"type":"begin","data":{"path":{"text":"match2.go"}}}`) {
+					return fmt.Errorf("expected begin event for match2.go, got: %s", output)
+				}
+				if !strings.Contains(output, `"submatches":[{"match":{"text":"peace"},"start":6,"end":11}]`) {
+					return fmt.Errorf("expected submatch for peace, got: %s", output)
+				}
+				if !strings.Contains(output, `{"type":"summary"`) {
+					return fmt.Errorf("expected summary event, got: %s", output)
+				}
+				return nil
+			},
+		},
 		{
 			name:        "no matches",
 			args:        []string{"nonexistent"},
@@ -720,6 +764,27 @@ hello world test
 				return nil
 			},
 		},
+		{
+			name:        "symbol search restricted by kind",
+			args:        []string{"-s", "-k", "method", "(?i)test"},
+			workDir:     tempDir,
+			expectError: false,
+			checkOutput: func(output string) error {
+				if !strings.Contains(output, "test.ts:6:1: method MyClass.testMethod") {
+					return fmt.Errorf("expected the method with its kind, got: %s", output)
+				}
+				if strings.Contains(output, "TestFunc") {
+					return fmt.Errorf("should not match functions, got: %s", output)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "kind filter without -s",
+			args:        []string{"-k", "function", "hello"},
+			workDir:     tempDir,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -766,3 +831,693 @@ hello world test
 		})
 	}
 }
+
+// TestRunSymbols_Integration tests the symbols subcommand
+func TestRunSymbols_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\ntype Server struct{}\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "app.py"), []byte("def run():\n    pass\n"), 0644)
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectError bool
+		want        string
+	}{
+		{
+			name: "single file",
+			args: []string{filepath.Join(tempDir, "main.go")},
+			want: filepath.Join(tempDir, "main.go") + ":3:5: struct Server\n" + filepath.Join(tempDir, "main.go") + ":5:5: func main\n",
+		},
+		{
+			name: "directory filtered by kind",
+			args: []string{"-k", "function", tempDir},
+			want: filepath.Join(tempDir, "app.py") + ":1:4: function run\n" + filepath.Join(tempDir, "main.go") + ":5:5: func main\n",
+		},
+		{
+			name:        "too many arguments",
+			args:        []string{tempDir, tempDir},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := runSymbols(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// TestRunRefs_Integration tests the refs subcommand
+func TestRunRefs_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	os.WriteFile(path, []byte("package main\n\n// helper is unused in comments\nfunc helper() {}\n\nfunc main() {\n\thelper()\n}\n"), 0644)
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectError bool
+		want        string
+	}{
+		{
+			name: "definition and call",
+			args: []string{"helper", tempDir},
+			want: path + ":4:5: definition func helper() {}\n" + path + ":7:1: reference \thelper()\n",
+		},
+		{
+			name: "max count",
+			args: []string{"--max-count", "1", "helper", tempDir},
+			want: path + ":4:5: definition func helper() {}\n",
+		},
+		{
+			name: "no matches",
+			args: []string{"missing", tempDir},
+			want: "",
+		},
+		{
+			name:        "missing name",
+			args:        []string{},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := runRefs(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// TestRunDef_Integration tests the def subcommand
+func TestRunDef_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, ".git"), 0755)
+	mainPath := filepath.Join(tempDir, "main.go")
+	utilPath := filepath.Join(tempDir, "util", "util.go")
+	os.MkdirAll(filepath.Dir(utilPath), 0755)
+	os.WriteFile(mainPath, []byte("package main\n\nfunc main() {\n\thelper()\n}\n"), 0644)
+	os.WriteFile(utilPath, []byte("package util\n\nfunc helper() {}\n"), 0644)
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectError bool
+		want        string
+	}{
+		{
+			name: "definition in another directory",
+			args: []string{mainPath + ":4:1"},
+			want: utilPath + ":3:5: func helper\n",
+		},
+		{
+			name: "definition in the same file",
+			args: []string{mainPath + ":3:5"},
+			want: mainPath + ":3:5: func main\n",
+		},
+		{
+			name:        "no identifier at position",
+			args:        []string{mainPath + ":2:0"},
+			expectError: true,
+		},
+		{
+			name:        "kind filter leaves no candidates",
+			args:        []string{"-k", "class", mainPath + ":4:1"},
+			expectError: true,
+		},
+		{
+			name:        "malformed position",
+			args:        []string{mainPath + ":4"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := runDef(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// TestRunIndex_Integration tests the index subcommand
+func TestRunIndex_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "app.py"), []byte("def run():\n    pass\n"), 0644)
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := runIndex([]string{tempDir})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "indexed 2 symbols in 2 files (2 parsed, 0 removed)\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".vtk", "index")); err != nil {
+		t.Errorf("expected the index directory to exist: %v", err)
+	}
+
+	// --text adds the trigram index
+	r, w, _ = os.Pipe()
+	os.Stdout = w
+	err = runIndex([]string{"--text", tempDir})
+	w.Close()
+	os.Stdout = oldStdout
+	buf.Reset()
+	io.Copy(&buf, r)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "indexed 2 symbols in 2 files (0 parsed, 0 removed)\nindexed 2 files for text search (2 read, 0 removed)\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	if err := runIndex([]string{filepath.Join(tempDir, "missing")}); err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}
+
+// TestRunTags_Integration tests the tags subcommand
+func TestRunTags_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	tests := []struct {
+		name     string
+		args     []string
+		tagsFile string
+		want     string
+	}{
+		{
+			name:     "ctags by default",
+			args:     []string{tempDir},
+			tagsFile: filepath.Join(tempDir, "tags"),
+			want:     "main\tmain.go\t/^func main() {}$/;\"\tkind:func\tline:3\n",
+		},
+		{
+			name:     "etags with -e",
+			args:     []string{"-e", tempDir},
+			tagsFile: filepath.Join(tempDir, "TAGS"),
+			want:     "\x0c\nmain.go,20\nfunc main\x7fmain\x013,14\n",
+		},
+		{
+			name:     "explicit output file",
+			args:     []string{"-f", filepath.Join(tempDir, "out", "..", "custom.tags"), tempDir},
+			tagsFile: filepath.Join(tempDir, "custom.tags"),
+			want:     "main\tmain.go\t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.MkdirAll(filepath.Join(tempDir, "out"), 0755)
+
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := runTags(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(buf.String(), "wrote 1 tags for 1 files to ") {
+				t.Errorf("unexpected output: %q", buf.String())
+			}
+			content, err := os.ReadFile(tt.tagsFile)
+			if err != nil {
+				t.Fatalf("expected a tag file: %v", err)
+			}
+			if !strings.Contains(string(content), tt.want) {
+				t.Errorf("expected %q in the tag file, got %q", tt.want, content)
+			}
+		})
+	}
+}
+
+// TestRunReplace_Integration tests the replace subcommand
+func TestRunDaemon_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	// Feed requests on stdin and capture stdout
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	inR, inW, _ := os.Pipe()
+	outR, outW, _ := os.Pipe()
+	os.Stdin, os.Stdout = inR, outW
+
+	inW.WriteString(`{"jsonrpc":"2.0","id":1,"method":"findSymbols","params":{"pattern":"^main$","dir":"` + tempDir + `"}}` + "\n")
+	inW.Close()
+	err := runDaemon(nil)
+
+	outW.Close()
+	os.Stdin, os.Stdout = oldStdin, oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, outR)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"jsonrpc":"2.0","id":1,"result":[{"path":"` + filepath.Join(tempDir, "main.go") + `","line":3,"column":5,"name":"main","kind":"func"}]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	if err := runDaemon([]string{"extra"}); err == nil || !strings.Contains(err.Error(), "usage: vtk daemon") {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestRunDaemon_Interrupt(t *testing.T) {
+	// Ctrl-C stops the daemon while stdin is still open
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	inR, inW, _ := os.Pipe()
+	outR, outW, _ := os.Pipe()
+	os.Stdin, os.Stdout = inR, outW
+	defer func() {
+		inW.Close()
+		outW.Close()
+		os.Stdin, os.Stdout = oldStdin, oldStdout
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- runDaemon(nil) }()
+
+	// A response shows the daemon is up and handling signals
+	inW.WriteString(`{"jsonrpc":"2.0","id":1,"method":"format","params":{"text":"{}"}}` + "\n")
+	if _, err := bufio.NewReader(outR).ReadString('\n'); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	process, _ := os.FindProcess(os.Getpid())
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot interrupt: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the daemon to stop on interrupt")
+	}
+}
+
+func TestRunPathFilters_Integration(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("// needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "notes.md"), []byte("needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "data.yaml"), []byte("needle: 1\n"), 0644)
+
+	tests := []struct {
+		name        string
+		run         func([]string) error
+		args        []string
+		expectError bool
+		want        string
+	}{
+		{
+			name: "find with -t",
+			run:  runFind,
+			args: []string{"-t", "go", "needle", tempDir},
+			want: filepath.Join(tempDir, "main.go") + ":1:3: // needle\n",
+		},
+		{
+			name: "find with repeated -T",
+			run:  runFind,
+			args: []string{"-T", "go", "-T", "yaml", "needle", tempDir},
+			want: filepath.Join(tempDir, "notes.md") + ":1:0: needle\n",
+		},
+		{
+			name: "glob with comma-separated -t",
+			run:  runGlob,
+			args: []string{"-t", "md,yaml", ".", tempDir},
+			want: filepath.Join(tempDir, "data.yaml") + "\n" + filepath.Join(tempDir, "notes.md") + "\n",
+		},
+		{
+			name: "find with --exclude",
+			run:  runFind,
+			args: []string{"--exclude", "*.go", "--exclude", "*.yaml", "needle", tempDir},
+			want: filepath.Join(tempDir, "notes.md") + ":1:0: needle\n",
+		},
+		{
+			name: "glob with --include and -T",
+			run:  runGlob,
+			args: []string{"--include", "*.md", "--include", "*.go", "-T", "go", ".", tempDir},
+			want: filepath.Join(tempDir, "notes.md") + "\n",
+		},
+		{
+			name:        "unknown type",
+			run:         runFind,
+			args:        []string{"-t", "nope", "needle", tempDir},
+			expectError: true,
+		},
+		{
+			name:        "glob -d with -t",
+			run:         runGlob,
+			args:        []string{"-d", "-t", "go", ".", tempDir},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := tt.run(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+
+	t.Run("type list", func(t *testing.T) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		err := runGlob([]string{"--type-list"})
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(buf.String(), "\ngo: *.go\n") || !strings.HasPrefix(buf.String(), "c: *.c, *.h\n") {
+			t.Errorf("unexpected type list:\n%s", buf.String())
+		}
+	})
+}
+
+func TestRunWalkControls_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tempDir, "sub", "deep"), 0755)
+	os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, ".hidden.txt"), []byte("needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "sub", "b.txt"), []byte("needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "sub", "deep", "big.txt"), []byte(strings.Repeat("needle\n", 300)), 0644)
+
+	tests := []struct {
+		name        string
+		run         func([]string) error
+		args        []string
+		expectError bool
+		want        string
+	}{
+		{
+			name: "hidden files are skipped",
+			run:  runGlob,
+			args: []string{"--max-filesize", "1K", ".", tempDir},
+			want: filepath.Join(tempDir, "a.txt") + "\n" + filepath.Join(tempDir, "sub", "b.txt") + "\n",
+		},
+		{
+			name: "find with --hidden and --max-depth",
+			run:  runFind,
+			args: []string{"--hidden", "--max-depth", "1", "needle", tempDir},
+			want: filepath.Join(tempDir, ".hidden.txt") + ":1:0: needle\n" + filepath.Join(tempDir, "a.txt") + ":1:0: needle\n",
+		},
+		{
+			name: "glob with -L and --one-file-system",
+			run:  runGlob,
+			args: []string{"-L", "--one-file-system", "--max-depth", "2", "b", tempDir},
+			want: filepath.Join(tempDir, "sub", "b.txt") + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture stdout
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			err := tt.run(tt.args)
+
+			w.Close()
+			os.Stdout = oldStdout
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRunReplace_Integration(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		expectError     bool
+		expectedContent string
+		checkOutput     func(string) error
+	}{
+		{
+			name:            "dry run prints diff",
+			args:            []string{"hello", "goodbye"},
+			expectError:     false,
+			expectedContent: "hello world\nsecond line\n",
+			checkOutput: func(output string) error {
+				if !strings.Contains(output, "-hello world\n+goodbye world\n") {
+					return fmt.Errorf("expected unified diff in output, got: %s", output)
+				}
+				return nil
+			},
+		},
+		{
+			name:            "write applies changes",
+			args:            []string{"--write", "hello", "goodbye"},
+			expectError:     false,
+			expectedContent: "goodbye world\nsecond line\n",
+			checkOutput: func(output string) error {
+				if !strings.Contains(output, "changed 1 lines in 1 files") {
+					return fmt.Errorf("expected summary in output, got: %s", output)
+				}
+				return nil
+			},
+		},
+		{
+			name:            "symbol rename",
+			args:            []string{"-s", "--write", "hello", "hi"},
+			expectError:     false,
+			expectedContent: "hi world\nsecond line\n",
+		},
+		{
+			name:            "missing replacement",
+			args:            []string{"hello"},
+			expectError:     true,
+			expectedContent: "hello world\nsecond line\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "greeting.py")
+			os.WriteFile(path, []byte("hello world\nsecond line\n"), 0644)
+
+			// Save current directory
+			oldDir, _ := os.Getwd()
+			defer os.Chdir(oldDir)
+			os.Chdir(tempDir)
+
+			// Capture stdout and discard stderr
+			oldStdout, oldStderr := os.Stdout, os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+			os.Stderr, _ = os.Open(os.DevNull)
+
+			err := runReplace(tt.args)
+
+			w.Close()
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+			output := buf.String()
+
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			content, _ := os.ReadFile(path)
+			if string(content) != tt.expectedContent {
+				t.Errorf("expected file content %q, got %q", tt.expectedContent, content)
+			}
+
+			if tt.checkOutput != nil {
+				if err := tt.checkOutput(output); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+// TestRunUndo_Integration tests reverting a replace with the undo subcommand
+func TestRunUndo_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "greeting.py")
+	os.WriteFile(path, []byte("hello world\n"), 0644)
+
+	// Save current directory
+	oldDir, _ := os.Getwd()
+	defer os.Chdir(oldDir)
+	os.Chdir(tempDir)
+
+	// Silence command output
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	os.Stdout, _ = os.Open(os.DevNull)
+	os.Stderr = os.Stdout
+	defer func() { os.Stdout, os.Stderr = oldStdout, oldStderr }()
+
+	if err := runUndo(nil); err == nil {
+		t.Error("expected error when there is nothing to undo")
+	}
+
+	if err := runReplace([]string{"--write", "hello", "goodbye"}); err != nil {
+		t.Fatalf("unexpected replace error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "goodbye world\n" {
+		t.Fatalf("expected replaced content, got %q", content)
+	}
+
+	if err := runUndo(nil); err != nil {
+		t.Fatalf("unexpected undo error: %v", err)
+	}
+	content, _ = os.ReadFile(path)
+	if string(content) != "hello world\n" {
+		t.Errorf("expected original content after undo, got %q", content)
+	}
+}
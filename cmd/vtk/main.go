@@ -3,14 +3,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/schollz/progressbar/v3"
+	"github.com/vishnuvyas/vtk/internal/daemon"
 	"github.com/vishnuvyas/vtk/internal/finder"
 	"github.com/vishnuvyas/vtk/internal/format"
 	"github.com/vishnuvyas/vtk/internal/stedi"
@@ -25,7 +33,7 @@ func main() {
 
 func run() error {
 	if len(os.Args) < 2 {
-		return fmt.Errorf("usage: vtk <command> [options]\n\nAvailable commands:\n  format    Format input data (supports -f flag)\n  find      Search for pattern in files (respects .gitignore)\n  glob      List files/directories matching regex pattern")
+		return fmt.Errorf("usage: vtk <command> [options]\n\nAvailable commands:\n  format    Format input data (supports -f flag)\n  find      Search for pattern in files (respects .gitignore)\n  symbols   List the symbols defined in a file or directory\n  refs      Find definitions of and references to a symbol\n  def       Find the definition of the identifier at a file position\n  index     Build or update the symbol and text indexes used by find\n  tags      Write a ctags tags or Emacs TAGS file\n  glob      List files/directories matching regex pattern\n  replace   Replace pattern in files, previewing a diff by default\n  undo      Revert the last replace run\n  daemon    Serve searches to editors over JSON-RPC")
 	}
 
 	// load environment variables
@@ -40,15 +48,75 @@ func run() error {
 		return runFormat(os.Args[2:])
 	case "find":
 		return runFind(os.Args[2:])
+	case "symbols":
+		return runSymbols(os.Args[2:])
+	case "refs":
+		return runRefs(os.Args[2:])
+	case "def":
+		return runDef(os.Args[2:])
+	case "index":
+		return runIndex(os.Args[2:])
+	case "tags":
+		return runTags(os.Args[2:])
 	case "glob":
 		return runGlob(os.Args[2:])
+	case "replace":
+		return runReplace(os.Args[2:])
+	case "undo":
+		return runUndo(os.Args[2:])
+	case "daemon":
+		return runDaemon(os.Args[2:])
 	case "stedi":
 		return runStedi(os.Args[2:])
 	default:
-		return fmt.Errorf("unknown command: %q\n\nAvailable commands:\n  format    Format input data (supports -f flag)\n  find      Search for pattern in files (respects .gitignore)\n  glob      List files/directories matching regex pattern", command)
+		return fmt.Errorf("unknown command: %q\n\nAvailable commands:\n  format    Format input data (supports -f flag)\n  find      Search for pattern in files (respects .gitignore)\n  symbols   List the symbols defined in a file or directory\n  refs      Find definitions of and references to a symbol\n  def       Find the definition of the identifier at a file position\n  index     Build or update the symbol and text indexes used by find\n  tags      Write a ctags tags or Emacs TAGS file\n  glob      List files/directories matching regex pattern\n  replace   Replace pattern in files, previewing a diff by default\n  undo      Revert the last replace run\n  daemon    Serve searches to editors over JSON-RPC", command)
 	}
 }
 
+func runDaemon(args []string) error {
+	// Create a new flag set for the daemon command
+	daemonCmd := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := daemonCmd.String("socket", "", "listen on this Unix socket instead of stdin and stdout")
+
+	// Parse flags
+	if err := daemonCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if daemonCmd.NArg() > 0 {
+		return fmt.Errorf("usage: vtk daemon [--socket path]\n\nServe find, findSymbols, glob, replace and format requests over JSON-RPC 2.0,\nkeeping ignore rules and symbol indexes cached between requests. Messages\nare framed with Content-Length headers or sent one per line\n  --socket  listen on a Unix socket instead of stdin and stdout")
+	}
+
+	// Stop cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := daemon.NewServer()
+	if *socket == "" {
+		// Reading stdin cannot be interrupted, so stop waiting for it instead
+		done := make(chan error, 1)
+		go func() { done <- server.ServeConn(ctx, os.Stdin, os.Stdout) }()
+		select {
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return fmt.Errorf("daemon failed: %w", err)
+			}
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	listener, err := daemon.ListenUnix(*socket)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *socket)
+	if err := server.Serve(ctx, listener); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("daemon failed: %w", err)
+	}
+	return nil
+}
+
 func runStedi(args []string) error {
 	stediCmd := flag.NewFlagSet("stedi", flag.ExitOnError)
 	key := stediCmd.String("k", os.Getenv("STEDI_API_KEY"), "stedi api key")
@@ -143,16 +211,40 @@ func runFind(args []string) error {
 	// Create a new flag set for the find command
 	findCmd := flag.NewFlagSet("find", flag.ExitOnError)
 	symbolSearch := findCmd.Bool("s", false, "search for symbols in code files (typescript, tsx, js, jsx, go, python, sql)")
+	workers := findCmd.Int("j", 0, "number of files to search in parallel (default: number of CPUs)")
+	afterContext := findCmd.Int("A", 0, "print `num` lines of context after each match")
+	beforeContext := findCmd.Int("B", 0, "print `num` lines of context before each match")
+	bothContext := findCmd.Int("C", 0, "print `num` lines of context before and after each match")
+	allMatches := findCmd.Bool("all", false, "report every match on a line, not just the first")
+	multiline := findCmd.Bool("U", false, "match the pattern against whole files so matches may span lines")
+	maxCount := findCmd.Int("max-count", 0, "stop after `num` results (default: no limit)")
+	jsonOutput := findCmd.Bool("json", false, "print results as JSON Lines (ripgrep --json schema)")
+	kinds := findCmd.String("k", "", "with -s, only match symbols of these comma-separated `kinds` (e.g. function,class)")
+	var types, excludeTypes typeFlags
+	findCmd.Var(&types, "t", "only search files of this `type` (repeatable, e.g. go or go,md)")
+	findCmd.Var(&excludeTypes, "T", "do not search files of this `type` (repeatable)")
+	typeList := findCmd.Bool("type-list", false, "list the file types -t and -T accept and exit")
+	var include, exclude globFlags
+	findCmd.Var(&include, "include", "only search paths matching this gitignore-style `glob` (repeatable)")
+	findCmd.Var(&exclude, "exclude", "do not search paths matching this gitignore-style `glob` (repeatable)")
+	walk := addWalkFlags(findCmd)
 
 	// Parse flags
 	if err := findCmd.Parse(args); err != nil {
 		return fmt.Errorf("failed to parse flags: %w", err)
 	}
+	if *typeList {
+		return printTypeList()
+	}
 
 	// Get remaining arguments (pattern and optional directory)
 	remainingArgs := findCmd.Args()
 	if len(remainingArgs) < 1 {
-		return fmt.Errorf("usage: vtk find [-s] <pattern> [directory]\n\nSearch for a regex pattern in files\n  -s    search for symbols in code files")
+		return fmt.Errorf("usage: vtk find [-s] [-k kinds] [-t type] [-T type] [--include glob] [--exclude glob] [--hidden] [-L] [--max-depth num] [--max-filesize size] [--one-file-system] [-j workers] [-A num] [-B num] [-C num] [--all] [-U] [--max-count num] [--json] <pattern> [directory]\n\nSearch for a regex pattern in files\n  -s    search for symbols in code files\n  -k    with -s, only match symbols of these comma-separated kinds\n  -t    only search files of this type (see --type-list)\n  -T    do not search files of this type\n  --include  only search paths matching this glob (e.g. 'internal/**')\n  --exclude  do not search paths matching this glob (e.g. '*_test.go')\n" + walkUsage + "  -j    number of files to search in parallel\n  -A    lines of context after each match\n  -B    lines of context before each match\n  -C    lines of context before and after each match\n  --all report every match on a line\n  -U    multiline mode, matches may span lines\n  --max-count  stop after this many results\n  --json       print results as JSON Lines")
+	}
+
+	if *kinds != "" && !*symbolSearch {
+		return fmt.Errorf("-k requires -s")
 	}
 
 	pattern := remainingArgs[0]
@@ -164,23 +256,404 @@ func runFind(args []string) error {
 	}
 
 	// Perform search (symbol or text)
-	var results []finder.Result
-	var err error
+	opts := finder.Options{
+		Workers:       *workers,
+		BeforeContext: *beforeContext,
+		AfterContext:  *afterContext,
+		AllMatches:    *allMatches,
+		Multiline:     *multiline,
+		MaxCount:      *maxCount,
+		Kinds:         splitKinds(*kinds),
+		Types:         types,
+		ExcludeTypes:  excludeTypes,
+		Include:       include,
+		Exclude:       exclude,
+	}
+	walk.apply(&opts)
+
+	// -C applies to whichever side was not set explicitly
+	if opts.BeforeContext == 0 {
+		opts.BeforeContext = *bothContext
+	}
+	if opts.AfterContext == 0 {
+		opts.AfterContext = *bothContext
+	}
 
+	// Stop cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Print results as they arrive, in Emacs compilation mode format by default
+	var write func(finder.Result) error
+	closeOutput := func() error { return nil }
+	if *jsonOutput {
+		w := finder.NewJSONWriter(os.Stdout)
+		write = w.Write
+		if *symbolSearch {
+			write = w.WriteSymbol
+		}
+		closeOutput = w.Close
+	} else {
+		w := finder.NewEmacsWriter(os.Stdout, opts.BeforeContext > 0 || opts.AfterContext > 0)
+		write = w.Write
+	}
+
+	var err error
 	if *symbolSearch {
-		results, err = finder.FindSymbols(dir, pattern)
+		err = finder.FindSymbolsStream(ctx, dir, pattern, opts, write)
+	} else {
+		err = finder.FindStream(ctx, dir, pattern, opts, write)
+	}
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	return closeOutput()
+}
+
+func runSymbols(args []string) error {
+	// Create a new flag set for the symbols command
+	symbolsCmd := flag.NewFlagSet("symbols", flag.ExitOnError)
+	kinds := symbolsCmd.String("k", "", "only list symbols of these comma-separated `kinds` (e.g. function,class)")
+	workers := symbolsCmd.Int("j", 0, "number of files to parse in parallel (default: number of CPUs)")
+	jsonOutput := symbolsCmd.Bool("json", false, "print results as JSON Lines (ripgrep --json schema)")
+
+	// Parse flags
+	if err := symbolsCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (optional file or directory)
+	remainingArgs := symbolsCmd.Args()
+	if len(remainingArgs) > 1 {
+		return fmt.Errorf("usage: vtk symbols [-k kinds] [-j workers] [--json] [file|directory]\n\nList the symbols defined in a code file or in every code file under a directory\n  -k      only list symbols of these comma-separated kinds\n  -j      number of files to parse in parallel\n  --json  print results as JSON Lines")
+	}
+
+	path := "."
+	if len(remainingArgs) == 1 {
+		path = remainingArgs[0]
+	}
+
+	opts := finder.Options{
+		Workers: *workers,
+		Kinds:   splitKinds(*kinds),
+	}
+
+	// Stop cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Print symbols as they arrive, in Emacs compilation mode format by default
+	var write func(finder.Result) error
+	closeOutput := func() error { return nil }
+	if *jsonOutput {
+		w := finder.NewJSONWriter(os.Stdout)
+		write = w.WriteSymbol
+		closeOutput = w.Close
 	} else {
-		results, err = finder.Find(dir, pattern)
+		write = finder.NewEmacsWriter(os.Stdout, false).Write
+	}
+
+	if err := finder.ListSymbolsStream(ctx, path, opts, write); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("listing symbols failed: %w", err)
+	}
+
+	return closeOutput()
+}
+
+func runRefs(args []string) error {
+	// Create a new flag set for the refs command
+	refsCmd := flag.NewFlagSet("refs", flag.ExitOnError)
+	workers := refsCmd.Int("j", 0, "number of files to scan in parallel (default: number of CPUs)")
+	maxCount := refsCmd.Int("max-count", 0, "stop after `num` results (default: no limit)")
+
+	// Parse flags
+	if err := refsCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (name and optional directory)
+	remainingArgs := refsCmd.Args()
+	if len(remainingArgs) < 1 || len(remainingArgs) > 2 {
+		return fmt.Errorf("usage: vtk refs [-j workers] [--max-count num] <name> [directory]\n\nFind the occurrences of a symbol in code files, outside comments and strings,\neach tagged as a definition or a reference. A qualified name such as\nClient.fetch only counts that symbol's definitions\n  -j           number of files to scan in parallel\n  --max-count  stop after this many results")
+	}
+
+	name := remainingArgs[0]
+	dir := "."
+	if len(remainingArgs) > 1 {
+		dir = remainingArgs[1]
+	}
+
+	opts := finder.Options{
+		Workers:  *workers,
+		MaxCount: *maxCount,
 	}
 
+	// Stop cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Print occurrences as they arrive, in Emacs compilation mode format
+	write := finder.NewEmacsWriter(os.Stdout, false).Write
+	if err := finder.FindRefsStream(ctx, dir, name, opts, write); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	return nil
+}
+
+func runDef(args []string) error {
+	// Create a new flag set for the def command
+	defCmd := flag.NewFlagSet("def", flag.ExitOnError)
+	kinds := defCmd.String("k", "", "only consider symbols of these comma-separated `kinds` (e.g. function,class)")
+	maxCount := defCmd.Int("max-count", 0, "print at most `num` candidates (default: no limit)")
+
+	// Parse flags
+	if err := defCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	usage := fmt.Errorf("usage: vtk def [-k kinds] [--max-count num] <file>:<line>:<column>\n\nFind the definitions of the identifier at a position, where lines count from 1\nand columns are byte offsets from 0 as in vtk's output. Candidates in the\nsame file come first, then those in the same directory, then the rest of\nthe repository\n  -k           only consider symbols of these comma-separated kinds\n  --max-count  print at most this many candidates")
+
+	// Get the position argument
+	remainingArgs := defCmd.Args()
+	if len(remainingArgs) != 1 {
+		return usage
+	}
+	path, line, column, ok := finder.ParsePosition(remainingArgs[0])
+	if !ok {
+		return usage
+	}
+
+	name, err := finder.IdentifierAt(path, line, column)
+	if err != nil {
+		return err
+	}
+
+	opts := finder.Options{
+		MaxCount: *maxCount,
+		Kinds:    splitKinds(*kinds),
+	}
+	results, err := finder.FindDefinitions(path, name, opts)
 	if err != nil {
 		return fmt.Errorf("search failed: %w", err)
 	}
+	if len(results) == 0 {
+		return fmt.Errorf("no definition found for %s", name)
+	}
+
+	// Print candidates in Emacs compilation mode format
+	fmt.Print(finder.FormatEmacsOutput(results))
+	return nil
+}
+
+func runIndex(args []string) error {
+	// Create a new flag set for the index command
+	indexCmd := flag.NewFlagSet("index", flag.ExitOnError)
+	workers := indexCmd.Int("j", 0, "number of files to parse in parallel (default: number of CPUs)")
+	text := indexCmd.Bool("text", false, "also build the trigram index used by find")
+
+	// Parse flags
+	if err := indexCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (optional directory)
+	remainingArgs := indexCmd.Args()
+	if len(remainingArgs) > 1 {
+		return fmt.Errorf("usage: vtk index [-j workers] [--text] [directory]\n\nBuild or update the symbol index of a directory tree, which later find -s\nand symbols runs reuse, re-parsing only the files that changed\n  -j      number of files to parse in parallel\n  --text  also build the trigram index that lets find skip files that cannot match")
+	}
+
+	dir := "."
+	if len(remainingArgs) == 1 {
+		dir = remainingArgs[0]
+	}
+
+	opts := finder.Options{Workers: *workers}
+	stats, err := finder.BuildSymbolIndex(dir, opts)
+	if err != nil {
+		return fmt.Errorf("indexing failed: %w", err)
+	}
+	fmt.Printf("indexed %d symbols in %d files (%d parsed, %d removed)\n", stats.Symbols, stats.Files, stats.Parsed, stats.Removed)
+
+	if *text {
+		stats, err := finder.BuildTextIndex(dir, opts)
+		if err != nil {
+			return fmt.Errorf("text indexing failed: %w", err)
+		}
+		fmt.Printf("indexed %d files for text search (%d read, %d removed)\n", stats.Files, stats.Parsed, stats.Removed)
+	}
+	return nil
+}
+
+func runTags(args []string) error {
+	// Create a new flag set for the tags command
+	tagsCmd := flag.NewFlagSet("tags", flag.ExitOnError)
+	etags := tagsCmd.Bool("e", false, "write an Emacs TAGS file instead of a ctags tags file")
+	output := tagsCmd.String("f", "", "write the tag file to `file` (default: tags or TAGS in the directory)")
+	workers := tagsCmd.Int("j", 0, "number of files to parse in parallel (default: number of CPUs)")
+
+	// Parse flags
+	if err := tagsCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (optional directory)
+	remainingArgs := tagsCmd.Args()
+	if len(remainingArgs) > 1 {
+		return fmt.Errorf("usage: vtk tags [-e] [-f file] [-j workers] [directory]\n\nWrite a tag file for the code files of a directory tree, respecting .gitignore.\nSymbols are kept in the symbol index, so regenerating only parses changed files\n  -e  write an Emacs TAGS file instead of a ctags tags file\n  -f  write the tag file to this path\n  -j  number of files to parse in parallel")
+	}
+
+	dir := "."
+	if len(remainingArgs) == 1 {
+		dir = remainingArgs[0]
+	}
+
+	format := finder.CtagsFormat
+	path := filepath.Join(dir, "tags")
+	if *etags {
+		format = finder.EtagsFormat
+		path = filepath.Join(dir, "TAGS")
+	}
+	if *output != "" {
+		path = *output
+	}
+
+	stats, err := finder.WriteTags(dir, path, format, finder.Options{Workers: *workers})
+	if err != nil {
+		return fmt.Errorf("writing tags failed: %w", err)
+	}
+
+	fmt.Printf("wrote %d tags for %d files to %s (%d parsed)\n", stats.Symbols, stats.Files, path, stats.Parsed)
+	return nil
+}
+
+// splitKinds splits the comma-separated value of a -k flag into kinds.
+func splitKinds(value string) []string {
+	var kinds []string
+	for _, kind := range strings.Split(value, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// typeFlags is the value of a -t or -T flag, which may be repeated and
+// holds one or more comma-separated file type names.
+type typeFlags []string
+
+func (t *typeFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *typeFlags) Set(value string) error {
+	*t = append(*t, splitKinds(value)...)
+	return nil
+}
+
+// globFlags is the value of an --include or --exclude flag, which may be
+// repeated and holds one glob each time.
+type globFlags []string
+
+func (g *globFlags) String() string {
+	return strings.Join(*g, " ")
+}
+
+func (g *globFlags) Set(value string) error {
+	*g = append(*g, value)
+	return nil
+}
+
+// walkFlags holds the flags that control how find, glob and replace walk a
+// directory tree.
+type walkFlags struct {
+	hidden        bool
+	follow        bool
+	maxDepth      int
+	maxFileSize   sizeFlag
+	oneFileSystem bool
+}
+
+// walkUsage describes the walk flags in the usage messages.
+const walkUsage = "  --hidden  include hidden files and directories\n  -L, --follow  follow symbolic links to directories\n  --max-depth  descend at most this many directories deep\n  --max-filesize  skip files larger than this size (e.g. 512K, 10M)\n  --one-file-system  do not descend into other file systems\n"
+
+// addWalkFlags registers the walk flags on a command's flag set.
+func addWalkFlags(cmd *flag.FlagSet) *walkFlags {
+	w := &walkFlags{}
+	cmd.BoolVar(&w.hidden, "hidden", false, "include hidden files and directories")
+	cmd.BoolVar(&w.follow, "L", false, "follow symbolic links to directories")
+	cmd.BoolVar(&w.follow, "follow", false, "follow symbolic links to directories")
+	cmd.IntVar(&w.maxDepth, "max-depth", 0, "descend at most `num` directories below the search directory (default: no limit)")
+	cmd.Var(&w.maxFileSize, "max-filesize", "skip files larger than `size` bytes, with an optional K, M or G suffix")
+	cmd.BoolVar(&w.oneFileSystem, "one-file-system", false, "do not descend into directories on other file systems")
+	return w
+}
+
+// apply sets the walk control fields of opts from the flags.
+func (w *walkFlags) apply(opts *finder.Options) {
+	opts.Hidden = w.hidden
+	opts.FollowSymlinks = w.follow
+	opts.MaxDepth = w.maxDepth
+	opts.MaxFileSize = int64(w.maxFileSize)
+	opts.OneFileSystem = w.oneFileSystem
+}
+
+// sizeFlag is the value of a --max-filesize flag, a number of bytes that
+// may end in K, M or G for powers of 1024.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = sizeFlag(size)
+	return nil
+}
 
-	// Format and print results in Emacs compilation mode format
-	output := finder.FormatEmacsOutput(results)
-	fmt.Print(output)
+// parseSize parses a size such as 100, 512K or 10M into a number of bytes.
+func parseSize(value string) (int64, error) {
+	digits := strings.TrimSpace(value)
+	multiplier := int64(1)
+	if n := len(digits); n > 0 {
+		switch digits[n-1] {
+		case 'k', 'K':
+			multiplier = 1 << 10
+		case 'm', 'M':
+			multiplier = 1 << 20
+		case 'g', 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			digits = digits[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || size < 0 || size > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size %q (expected a number of bytes like 100, 512K or 10M)", value)
+	}
+	return size * multiplier, nil
+}
 
+// printTypeList prints the known file types and their globs, one per line.
+func printTypeList() error {
+	types, err := finder.FileTypes()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", name, strings.Join(types[name], ", "))
+	}
 	return nil
 }
 
@@ -188,16 +661,32 @@ func runGlob(args []string) error {
 	// Create a new flag set for the glob command
 	globCmd := flag.NewFlagSet("glob", flag.ExitOnError)
 	matchDirectories := globCmd.Bool("d", false, "match directory names instead of file names")
+	jsonOutput := globCmd.Bool("json", false, "print results as JSON Lines")
+	var types, excludeTypes typeFlags
+	globCmd.Var(&types, "t", "only list files of this `type` (repeatable, e.g. go or go,md)")
+	globCmd.Var(&excludeTypes, "T", "do not list files of this `type` (repeatable)")
+	typeList := globCmd.Bool("type-list", false, "list the file types -t and -T accept and exit")
+	var include, exclude globFlags
+	globCmd.Var(&include, "include", "only list paths matching this gitignore-style `glob` (repeatable)")
+	globCmd.Var(&exclude, "exclude", "do not list paths matching this gitignore-style `glob` (repeatable)")
+	walk := addWalkFlags(globCmd)
 
 	// Parse flags
 	if err := globCmd.Parse(args); err != nil {
 		return fmt.Errorf("failed to parse flags: %w", err)
 	}
+	if *typeList {
+		return printTypeList()
+	}
 
 	// Get remaining arguments (pattern and optional directory)
 	remainingArgs := globCmd.Args()
 	if len(remainingArgs) < 1 {
-		return fmt.Errorf("usage: vtk glob [-d] <pattern> [directory]\n\nList files/directories matching regex pattern\n  -d    match directory names instead of file names")
+		return fmt.Errorf("usage: vtk glob [-d] [-t type] [-T type] [--include glob] [--exclude glob] [--hidden] [-L] [--max-depth num] [--max-filesize size] [--one-file-system] [--json] <pattern> [directory]\n\nList files/directories matching regex pattern\n  -d      match directory names instead of file names\n  -t      only list files of this type (see --type-list)\n  -T      do not list files of this type\n  --include  only list paths matching this glob (e.g. 'internal/**')\n  --exclude  do not list paths matching this glob (e.g. '*_test.go')\n" + walkUsage + "  --json  print results as JSON Lines")
+	}
+
+	if *matchDirectories && (len(types) > 0 || len(excludeTypes) > 0) {
+		return fmt.Errorf("-t and -T cannot be used with -d")
 	}
 
 	pattern := remainingArgs[0]
@@ -208,24 +697,156 @@ func runGlob(args []string) error {
 		dir = remainingArgs[1]
 	}
 
+	// Stop cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Print results (one path per line) as they are found
+	printPath := func(result finder.Result) error {
+		_, err := fmt.Println(result.Path)
+		return err
+	}
+	closeOutput := func() error { return nil }
+	if *jsonOutput {
+		w := finder.NewJSONWriter(os.Stdout)
+		printPath = w.WritePath
+		closeOutput = w.Close
+	}
+
 	// Perform glob search (files or directories)
-	var results []finder.Result
 	var err error
-
+	opts := finder.Options{Types: types, ExcludeTypes: excludeTypes, Include: include, Exclude: exclude}
+	walk.apply(&opts)
 	if *matchDirectories {
-		results, err = finder.GlobDirectories(dir, pattern)
+		err = finder.GlobDirectoriesStream(ctx, dir, pattern, opts, printPath)
 	} else {
-		results, err = finder.GlobFiles(dir, pattern)
+		err = finder.GlobFilesStream(ctx, dir, pattern, opts, printPath)
 	}
 
-	if err != nil {
+	if err != nil && !errors.Is(err, context.Canceled) {
 		return fmt.Errorf("glob failed: %w", err)
 	}
 
-	// Print results (one path per line)
-	for _, result := range results {
-		fmt.Println(result.Path)
+	return closeOutput()
+}
+
+func runReplace(args []string) error {
+	// Create a new flag set for the replace command
+	replaceCmd := flag.NewFlagSet("replace", flag.ExitOnError)
+	symbolRename := replaceCmd.Bool("s", false, "rename a symbol in code files instead of replacing a regex")
+	write := replaceCmd.Bool("write", false, "apply the changes instead of printing a diff")
+	walk := addWalkFlags(replaceCmd)
+
+	// Parse flags
+	if err := replaceCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (pattern, replacement and optional directory)
+	remainingArgs := replaceCmd.Args()
+	if len(remainingArgs) < 2 {
+		return fmt.Errorf("usage: vtk replace [-s] [--write] [--hidden] [-L] [--max-depth num] [--max-filesize size] [--one-file-system] <pattern> <replacement> [directory]\n\nReplace a regex pattern in files, printing a unified diff unless --write is given\n  -s       rename a symbol in code files. A Go symbol declared more than once\n           must be qualified, as in pkg.Type.Method, or given by file:line:column\n" + walkUsage + "  --write  apply the changes")
+	}
+
+	pattern := remainingArgs[0]
+	replacement := remainingArgs[1]
+	dir := "."
+
+	// Optional directory argument
+	if len(remainingArgs) > 2 {
+		dir = remainingArgs[2]
+	}
+
+	// Compute the changes (symbol rename or text replace)
+	var changes []finder.Change
+	var err error
+
+	var opts finder.Options
+	walk.apply(&opts)
+	if *symbolRename {
+		changes, err = finder.PlanReplaceSymbol(dir, pattern, replacement, opts)
+	} else {
+		changes, err = finder.PlanReplace(dir, pattern, replacement, opts)
+	}
+
+	if err != nil {
+		return fmt.Errorf("replace failed: %w", err)
+	}
+
+	// Count changed lines across all files
+	lines := 0
+	for _, change := range changes {
+		seen := make(map[int]bool)
+		for _, result := range change.Results {
+			seen[result.Line] = true
+		}
+		lines += len(seen)
+	}
+
+	if !*write {
+		// Preview the changes as a unified diff. The summary goes to stderr
+		// so that the output can be piped to patch.
+		for _, change := range changes {
+			fmt.Print(finder.UnifiedDiff(change))
+		}
+		fmt.Fprintf(os.Stderr, "%d lines in %d files would change (use --write to apply)\n", lines, len(changes))
+		return nil
+	}
+
+	description := fmt.Sprintf("replace %q with %q", pattern, replacement)
+	if *symbolRename {
+		description = fmt.Sprintf("rename %s to %s", pattern, replacement)
+	}
+	journal, err := finder.ApplyChangesWithJournal(dir, description, changes)
+	if err != nil {
+		return fmt.Errorf("replace failed: %w", err)
+	}
+
+	fmt.Printf("changed %d lines in %d files\n", lines, len(changes))
+	if journal != nil {
+		fmt.Fprintf(os.Stderr, "recorded as run %s (use vtk undo to revert)\n", journal.ID)
+	}
+	return nil
+}
+
+func runUndo(args []string) error {
+	// Create a new flag set for the undo command
+	undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+	list := undoCmd.Bool("list", false, "list the replace runs that can be undone")
+	run := undoCmd.String("run", "", "undo the named run instead of the most recent one")
+
+	// Parse flags
+	if err := undoCmd.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	// Get remaining arguments (optional directory)
+	remainingArgs := undoCmd.Args()
+	if len(remainingArgs) > 1 {
+		return fmt.Errorf("usage: vtk undo [--list] [--run <id>] [directory]\n\nRevert the most recent replace run, or the one named by --run\n  --list  list the runs that can be undone")
+	}
+
+	dir := "."
+	if len(remainingArgs) > 0 {
+		dir = remainingArgs[0]
+	}
+
+	if *list {
+		journals, err := finder.ListJournals(dir)
+		if err != nil {
+			return fmt.Errorf("undo failed: %w", err)
+		}
+		for _, journal := range journals {
+			fmt.Printf("%s  %d files  %s\n", journal.ID, len(journal.Files), journal.Description)
+		}
+		return nil
+	}
+
+	journal, err := finder.Undo(dir, *run)
+	if err != nil {
+		return fmt.Errorf("undo failed: %w", err)
 	}
 
+	fmt.Printf("restored %d files from run %s (%s)\n", len(journal.Files), journal.ID, journal.Description)
 	return nil
 }
@@ -306,3 +306,39 @@ func TestFormatSQL_ErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input       string
+		want        int64
+		expectError bool
+	}{
+		{input: "100", want: 100},
+		{input: "512K", want: 512 << 10},
+		{input: "10m", want: 10 << 20},
+		{input: "2G", want: 2 << 30},
+		{input: "", expectError: true},
+		{input: "K", expectError: true},
+		{input: "10X", expectError: true},
+		{input: "-1", expectError: true},
+		{input: "9999999999999G", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error but got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
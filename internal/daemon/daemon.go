@@ -0,0 +1,525 @@
+// Package daemon serves vtk's searches, replacements and formatters to
+// editors over JSON-RPC 2.0, keeping ignore rules and indexes cached
+// between requests.
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/vishnuvyas/vtk/internal/finder"
+	"github.com/vishnuvyas/vtk/internal/format"
+)
+
+// Server answers JSON-RPC requests. Its methods are:
+//
+//   - find: text search, with params pattern, dir, before, after, context,
+//     all, multiline and maxCount, returning a list of matches
+//   - findSymbols: symbol search, with params pattern, dir, kinds and
+//     maxCount, returning a list of symbols
+//   - glob: path search, with params pattern, dir and directories,
+//     returning a list of paths
+//   - replace: regex replacement or symbol rename, with params pattern,
+//     replacement, dir, symbol and write, returning each file's diff and,
+//     when written, the id of the journal for vtk undo
+//   - format: formatting, with params text and format ("json" or "sql"),
+//     returning the formatted text
+//
+// The methods other than format also take the path filter params types,
+// excludeTypes, include and exclude, lists that work like vtk find's -t,
+// -T, --include and --exclude flags, and the walk params hidden,
+// followSymlinks, maxDepth, maxFileSize (in bytes) and oneFileSystem, which
+// work like its --hidden, -L, --max-depth, --max-filesize and
+// --one-file-system flags.
+//
+// Requests may also be sent in batches, arrays answered with an array of
+// responses. Relative directories are resolved against the daemon's
+// working directory, so clients should send absolute ones.
+type Server struct {
+	cache *finder.Cache
+}
+
+// NewServer returns a server with an empty cache.
+func NewServer() *Server {
+	return &Server{cache: finder.NewCache()}
+}
+
+// Serve accepts connections on l and serves each of them concurrently until
+// ctx is done, which also closes the open connections, or l fails.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			// Closing the connection unblocks a read waiting for a request
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			s.ServeConn(ctx, conn, conn)
+		}()
+	}
+}
+
+// ServeConn answers the requests read from r in order, writing responses to
+// w, until r ends or ctx is done. Each response is framed like its request:
+// with a Content-Length header or as a single line of JSON. A read blocked
+// waiting for a request is not interrupted by ctx; callers close r for
+// that.
+func (s *Server) ServeConn(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		body, framed, err := readMessage(reader)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			// The stream cannot be resynchronized after a framing error
+			return err
+		}
+
+		response := s.handle(ctx, body)
+		if response == nil {
+			continue
+		}
+		if err := writeMessage(w, response, framed); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+}
+
+// handle answers a single message, a request or a batch of them, returning
+// the encoded response or nil when there is nothing to answer. A batch is
+// answered with an array of the responses to its requests, in order,
+// leaving out notifications.
+func (s *Server) handle(ctx context.Context, body []byte) []byte {
+	if !json.Valid(body) {
+		return encodeError(nil, &rpcError{Code: codeParseError, Message: "parse error"})
+	}
+	body = bytes.TrimSpace(body)
+	if body[0] != '[' {
+		return s.handleRequest(ctx, body)
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+		return encodeError(nil, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+	var responses [][]byte
+	for _, raw := range batch {
+		if response := s.handleRequest(ctx, raw); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return append(append([]byte("["), bytes.Join(responses, []byte(","))...), ']')
+}
+
+// handleRequest answers a single request, returning the encoded response or
+// nil for a notification.
+func (s *Server) handleRequest(ctx context.Context, body []byte) []byte {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return encodeError(nil, &rpcError{Code: codeInvalidRequest, Message: "invalid request"})
+	}
+
+	result, err := s.call(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeServerError, Message: err.Error()}
+		}
+		return encodeError(req.ID, rpcErr)
+	}
+
+	response, err := json.Marshal(successResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	if err != nil {
+		return encodeError(req.ID, &rpcError{Code: codeServerError, Message: fmt.Sprintf("failed to encode result: %v", err)})
+	}
+	return response
+}
+
+// encodeError encodes an error response. A nil id encodes as null, as
+// required when the request's id could not be read.
+func encodeError(id json.RawMessage, rpcErr *rpcError) []byte {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	response, _ := json.Marshal(errorResponse{JSONRPC: "2.0", ID: id, Error: rpcErr})
+	return response
+}
+
+// call runs the named method.
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "find":
+		return s.find(ctx, params)
+	case "findSymbols":
+		return s.findSymbols(ctx, params)
+	case "glob":
+		return s.glob(ctx, params)
+	case "replace":
+		return s.replace(params)
+	case "format":
+		return s.format(params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+	}
+}
+
+// decodeParams decodes the params of a request into v.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return invalidParams("missing params")
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams("invalid params: %v", err)
+	}
+	return nil
+}
+
+// orDot returns dir, or "." when it is empty.
+func orDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// pathFilters holds the path filter and walk control params of the search
+// and replace methods.
+type pathFilters struct {
+	Types        []string `json:"types"`
+	ExcludeTypes []string `json:"excludeTypes"`
+	Include      []string `json:"include"`
+	Exclude      []string `json:"exclude"`
+
+	Hidden         bool  `json:"hidden"`
+	FollowSymlinks bool  `json:"followSymlinks"`
+	MaxDepth       int   `json:"maxDepth"`
+	MaxFileSize    int64 `json:"maxFileSize"`
+	OneFileSystem  bool  `json:"oneFileSystem"`
+}
+
+// options returns finder options with the filters and the server's cache.
+func (s *Server) options(filters pathFilters) finder.Options {
+	return finder.Options{
+		Types:          filters.Types,
+		ExcludeTypes:   filters.ExcludeTypes,
+		Include:        filters.Include,
+		Exclude:        filters.Exclude,
+		Hidden:         filters.Hidden,
+		FollowSymlinks: filters.FollowSymlinks,
+		MaxDepth:       filters.MaxDepth,
+		MaxFileSize:    filters.MaxFileSize,
+		OneFileSystem:  filters.OneFileSystem,
+		Cache:          s.cache,
+	}
+}
+
+// match is a text or symbol search result. Line is 1-based and Column is a
+// 0-based byte offset, as in vtk's other output.
+type match struct {
+	Path      string        `json:"path"`
+	Line      int           `json:"line"`
+	Column    int           `json:"column"`
+	EndLine   int           `json:"endLine,omitempty"`
+	EndColumn int           `json:"endColumn,omitempty"`
+	Text      string        `json:"text,omitempty"`    // line of a text match
+	Matched   string        `json:"matched,omitempty"` // text the pattern matched
+	Name      string        `json:"name,omitempty"`    // name of a symbol
+	Kind      string        `json:"kind,omitempty"`    // kind of a symbol
+	Before    []contextLine `json:"before,omitempty"`
+	After     []contextLine `json:"after,omitempty"`
+}
+
+// contextLine is a line of context around a text match.
+type contextLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// contextLines converts the context lines of a result.
+func contextLines(lines []finder.ContextLine) []contextLine {
+	var converted []contextLine
+	for _, line := range lines {
+		converted = append(converted, contextLine{Line: line.Line, Text: line.Text})
+	}
+	return converted
+}
+
+// find runs a text search.
+func (s *Server) find(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params struct {
+		Pattern   string `json:"pattern"`
+		Dir       string `json:"dir"`
+		Before    int    `json:"before"`
+		After     int    `json:"after"`
+		Context   int    `json:"context"`
+		All       bool   `json:"all"`
+		Multiline bool   `json:"multiline"`
+		MaxCount  int    `json:"maxCount"`
+		pathFilters
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Pattern == "" {
+		return nil, invalidParams("missing pattern")
+	}
+
+	opts := s.options(params.pathFilters)
+	opts.BeforeContext = max(params.Before, params.Context)
+	opts.AfterContext = max(params.After, params.Context)
+	opts.AllMatches = params.All
+	opts.Multiline = params.Multiline
+	opts.MaxCount = params.MaxCount
+	matches := []match{}
+	err := finder.FindStream(ctx, orDot(params.Dir), params.Pattern, opts, func(result finder.Result) error {
+		matches = append(matches, match{
+			Path:      result.Path,
+			Line:      result.Line,
+			Column:    result.Column,
+			EndLine:   result.EndLine,
+			EndColumn: result.EndColumn,
+			Text:      result.Match,
+			Matched:   result.Matched,
+			Before:    contextLines(result.Before),
+			After:     contextLines(result.After),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// findSymbols runs a symbol search.
+func (s *Server) findSymbols(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params struct {
+		Pattern  string   `json:"pattern"`
+		Dir      string   `json:"dir"`
+		Kinds    []string `json:"kinds"`
+		MaxCount int      `json:"maxCount"`
+		pathFilters
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Pattern == "" {
+		return nil, invalidParams("missing pattern")
+	}
+
+	opts := s.options(params.pathFilters)
+	opts.Kinds = params.Kinds
+	opts.MaxCount = params.MaxCount
+	matches := []match{}
+	err := finder.FindSymbolsStream(ctx, orDot(params.Dir), params.Pattern, opts, func(result finder.Result) error {
+		matches = append(matches, match{
+			Path:   result.Path,
+			Line:   result.Line,
+			Column: result.Column,
+			Name:   result.Match,
+			Kind:   result.Kind,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// glob lists the files or directories whose names match a pattern.
+func (s *Server) glob(ctx context.Context, raw json.RawMessage) (any, error) {
+	var params struct {
+		Pattern     string `json:"pattern"`
+		Dir         string `json:"dir"`
+		Directories bool   `json:"directories"`
+		pathFilters
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Pattern == "" {
+		return nil, invalidParams("missing pattern")
+	}
+
+	paths := []string{}
+	collect := func(result finder.Result) error {
+		paths = append(paths, result.Path)
+		return nil
+	}
+	opts := s.options(params.pathFilters)
+	var err error
+	if params.Directories {
+		err = finder.GlobDirectoriesStream(ctx, orDot(params.Dir), params.Pattern, opts, collect)
+	} else {
+		err = finder.GlobFilesStream(ctx, orDot(params.Dir), params.Pattern, opts, collect)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// fileChange is the change a replacement makes to one file.
+type fileChange struct {
+	Path  string `json:"path"`
+	Lines int    `json:"lines"` // lines changed
+	Diff  string `json:"diff"`  // unified diff of the change
+}
+
+// replaceResult is the result of the replace method.
+type replaceResult struct {
+	Files   []fileChange `json:"files"`
+	Written bool         `json:"written"`
+	Run     string       `json:"run,omitempty"` // journal id, when written
+}
+
+// replace previews or applies a regex replacement or symbol rename.
+func (s *Server) replace(raw json.RawMessage) (any, error) {
+	var params struct {
+		Pattern     string  `json:"pattern"`
+		Replacement *string `json:"replacement"`
+		Dir         string  `json:"dir"`
+		Symbol      bool    `json:"symbol"`
+		Write       bool    `json:"write"`
+		pathFilters
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Pattern == "" {
+		return nil, invalidParams("missing pattern")
+	}
+	if params.Replacement == nil {
+		return nil, invalidParams("missing replacement")
+	}
+
+	dir := orDot(params.Dir)
+	opts := s.options(params.pathFilters)
+	var changes []finder.Change
+	var err error
+	if params.Symbol {
+		changes, err = finder.PlanReplaceSymbol(dir, params.Pattern, *params.Replacement, opts)
+	} else {
+		changes, err = finder.PlanReplace(dir, params.Pattern, *params.Replacement, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := replaceResult{Files: []fileChange{}}
+	for _, change := range changes {
+		lines := make(map[int]bool)
+		for _, r := range change.Results {
+			lines[r.Line] = true
+		}
+		result.Files = append(result.Files, fileChange{
+			Path:  change.Path,
+			Lines: len(lines),
+			Diff:  finder.UnifiedDiff(change),
+		})
+	}
+	if !params.Write {
+		return result, nil
+	}
+
+	description := fmt.Sprintf("replace %q with %q", params.Pattern, *params.Replacement)
+	if params.Symbol {
+		description = fmt.Sprintf("rename %s to %s", params.Pattern, *params.Replacement)
+	}
+	journal, err := finder.ApplyChangesWithJournal(dir, description, changes)
+	if err != nil {
+		return nil, err
+	}
+	result.Written = true
+	if journal != nil {
+		result.Run = journal.ID
+	}
+	return result, nil
+}
+
+// format formats JSON or SQL text.
+func (s *Server) format(raw json.RawMessage) (any, error) {
+	var params struct {
+		Text   string `json:"text"`
+		Format string `json:"format"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	var text string
+	var err error
+	switch params.Format {
+	case "json", "":
+		text, err = format.PrettyJSON([]byte(params.Text))
+	case "sql":
+		text, err = format.PrettySQL([]byte(params.Text))
+	default:
+		return nil, invalidParams("unsupported format: %q (supported: json, sql)", params.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		Text string `json:"text"`
+	}{text}, nil
+}
+
+// ListenUnix listens on the Unix socket at path. A socket file left behind
+// by a daemon that is no longer running is replaced, but a live daemon's
+// socket or any other file is an error.
+func ListenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return l, nil
+}
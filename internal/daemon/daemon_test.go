@@ -0,0 +1,327 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// response is a decoded JSON-RPC response.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// serveLines sends newline-delimited requests to a server and returns its
+// responses.
+func serveLines(t *testing.T, server *Server, requests ...string) []response {
+	t.Helper()
+	var output strings.Builder
+	input := strings.Join(requests, "\n") + "\n"
+	if err := server.ServeConn(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var responses []response
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r response
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid response %q: %v", line, err)
+		}
+		responses = append(responses, r)
+	}
+	return responses
+}
+
+// call sends a single request and decodes its result into v.
+func call(t *testing.T, server *Server, method string, params any, v any) {
+	t.Helper()
+	encoded, _ := json.Marshal(params)
+	request := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":` + string(encoded) + `}`
+	responses := serveLines(t, server, request)
+	if len(responses) != 1 {
+		t.Fatalf("expected one response, got %d", len(responses))
+	}
+	if responses[0].Error != nil {
+		t.Fatalf("%s failed: %v", method, responses[0].Error)
+	}
+	if err := json.Unmarshal(responses[0].Result, v); err != nil {
+		t.Fatalf("invalid result %s: %v", responses[0].Result, err)
+	}
+}
+
+func TestServer_Find(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("one\nneedle here\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "b.txt"), []byte("needle\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("b.txt\n"), 0644)
+
+	server := NewServer()
+	var matches []match
+	call(t, server, "find", map[string]any{"pattern": "needle", "dir": tempDir, "before": 1}, &matches)
+	if len(matches) != 1 {
+		t.Fatalf("expected one match, got %+v", matches)
+	}
+	m := matches[0]
+	if m.Path != filepath.Join(tempDir, "a.txt") || m.Line != 2 || m.Column != 0 || m.EndColumn != 6 ||
+		m.Text != "needle here" || m.Matched != "needle" || len(m.Before) != 1 || m.Before[0].Text != "one" {
+		t.Errorf("unexpected match: %+v", m)
+	}
+
+	// Changing the ignore rules between calls takes effect
+	os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("a.txt\n#\n"), 0644)
+	call(t, server, "find", map[string]any{"pattern": "needle", "dir": tempDir}, &matches)
+	if len(matches) != 1 || matches[0].Path != filepath.Join(tempDir, "b.txt") {
+		t.Errorf("expected only b.txt to match, got %+v", matches)
+	}
+
+	// No matches is an empty list
+	var raw json.RawMessage
+	call(t, server, "find", map[string]any{"pattern": "missing", "dir": tempDir}, &raw)
+	if string(raw) != "[]" {
+		t.Errorf("expected an empty list, got %s", raw)
+	}
+}
+
+func TestServer_FindSymbols(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	old := time.Now().Add(-time.Hour)
+	os.WriteFile(path, []byte("package main\n\ntype Server struct{}\n\nfunc Serve() {}\n"), 0644)
+	os.Chtimes(path, old, old)
+
+	server := NewServer()
+	var matches []match
+	call(t, server, "findSymbols", map[string]any{"pattern": "^Serve", "dir": tempDir, "kinds": []string{"func"}}, &matches)
+	if len(matches) != 1 || matches[0].Name != "Serve" || matches[0].Kind != "func" || matches[0].Line != 5 {
+		t.Fatalf("unexpected symbols: %+v", matches)
+	}
+
+	// Edits between calls are seen
+	os.WriteFile(path, []byte("package main\n\nfunc Serve() {}\n\nfunc ServeTLS() {}\n"), 0644)
+	call(t, server, "findSymbols", map[string]any{"pattern": "^Serve", "dir": tempDir}, &matches)
+	if len(matches) != 2 || matches[1].Name != "ServeTLS" {
+		t.Errorf("expected the edited file's symbols, got %+v", matches)
+	}
+}
+
+func TestServer_Glob(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, "src"), 0755)
+	os.WriteFile(filepath.Join(tempDir, "src", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "README.md"), []byte("# readme\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, ".notes.md"), []byte("notes\n"), 0644)
+
+	server := NewServer()
+	var paths []string
+	call(t, server, "glob", map[string]any{"pattern": `\.go$`, "dir": tempDir}, &paths)
+	if len(paths) != 1 || paths[0] != filepath.Join(tempDir, "src", "main.go") {
+		t.Errorf("unexpected files: %v", paths)
+	}
+	call(t, server, "glob", map[string]any{"pattern": ".", "dir": tempDir, "types": []string{"md"}}, &paths)
+	if len(paths) != 1 || paths[0] != filepath.Join(tempDir, "README.md") {
+		t.Errorf("unexpected files of type md: %v", paths)
+	}
+	call(t, server, "glob", map[string]any{"pattern": ".", "dir": tempDir, "exclude": []string{"src/"}}, &paths)
+	if len(paths) != 1 || paths[0] != filepath.Join(tempDir, "README.md") {
+		t.Errorf("unexpected files outside src: %v", paths)
+	}
+	call(t, server, "glob", map[string]any{"pattern": ".", "dir": tempDir, "hidden": true, "maxDepth": 1}, &paths)
+	if len(paths) != 2 || paths[0] != filepath.Join(tempDir, ".notes.md") || paths[1] != filepath.Join(tempDir, "README.md") {
+		t.Errorf("unexpected top-level files with hidden ones: %v", paths)
+	}
+	call(t, server, "glob", map[string]any{"pattern": "^src$", "dir": tempDir, "directories": true}, &paths)
+	if len(paths) != 1 || paths[0] != filepath.Join(tempDir, "src") {
+		t.Errorf("unexpected directories: %v", paths)
+	}
+}
+
+func TestServer_Replace(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "a.txt")
+	os.WriteFile(path, []byte("hello world\n"), 0644)
+
+	server := NewServer()
+	var result replaceResult
+	call(t, server, "replace", map[string]any{"pattern": "world", "replacement": "there", "dir": tempDir}, &result)
+	if result.Written || len(result.Files) != 1 || result.Files[0].Lines != 1 || !strings.Contains(result.Files[0].Diff, "+hello there") {
+		t.Fatalf("unexpected preview: %+v", result)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "hello world\n" {
+		t.Fatalf("expected a preview to leave the file alone, got %q", content)
+	}
+
+	call(t, server, "replace", map[string]any{"pattern": "world", "replacement": "there", "dir": tempDir, "write": true}, &result)
+	if !result.Written || result.Run == "" {
+		t.Errorf("expected the change to be written and journaled, got %+v", result)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "hello there\n" {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestServer_Format(t *testing.T) {
+	server := NewServer()
+	var result struct{ Text string }
+	call(t, server, "format", map[string]any{"text": `{"a":[1]}`, "format": "json"}, &result)
+	if result.Text != "{\n  \"a\": [\n    1\n  ]\n}" {
+		t.Errorf("unexpected JSON: %q", result.Text)
+	}
+	call(t, server, "format", map[string]any{"text": "select a from b", "format": "sql"}, &result)
+	if !strings.Contains(result.Text, "SELECT") {
+		t.Errorf("unexpected SQL: %q", result.Text)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	tempDir := t.TempDir()
+	server := NewServer()
+
+	responses := serveLines(t, server,
+		`{"jsonrpc":"2.0","id":1,"method":"nope"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"find"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"find","params":{"dir":"`+tempDir+`"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"find","params":{"pattern":"(","dir":"`+tempDir+`"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"format","params":{"text":"x","format":"xml"}}`,
+		`{"jsonrpc":"2.0","method":"format","params":{"text":"[]"}}`,
+		`{not json`,
+		`{"id":6,"method":"find"}`,
+	)
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", codeMethodNotFound},
+		{"2", codeInvalidParams},
+		{"3", codeInvalidParams},
+		{"4", codeServerError},
+		{"5", codeInvalidParams},
+		// The notification gets no response
+		{"null", codeParseError},
+		{"null", codeInvalidRequest},
+	}
+	if len(responses) != len(want) {
+		t.Fatalf("expected %d responses, got %d", len(want), len(responses))
+	}
+	for i, w := range want {
+		r := responses[i]
+		if string(r.ID) != w.id || r.Error == nil || r.Error.Code != w.code || r.Result != nil {
+			t.Errorf("response %d: expected error %d for id %s, got %+v", i, w.code, w.id, r)
+		}
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	server := NewServer()
+	var output strings.Builder
+	input := `[{"jsonrpc":"2.0","id":1,"method":"format","params":{"text":"[]"}},` +
+		`{"jsonrpc":"2.0","method":"format","params":{"text":"[]"}},` +
+		`1,` +
+		`{"jsonrpc":"2.0","id":2,"method":"nope"}]` + "\n" +
+		`[{"jsonrpc":"2.0","method":"format","params":{"text":"[]"}}]` + "\n" +
+		`[]` + "\n"
+	if err := server.ServeConn(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a response to the first and last batches, got %q", output.String())
+	}
+
+	// Notifications get no response, and the others answer in order
+	var responses []response
+	if err := json.Unmarshal([]byte(lines[0]), &responses); err != nil {
+		t.Fatalf("invalid batch response %q: %v", lines[0], err)
+	}
+	if len(responses) != 3 || string(responses[0].ID) != "1" || string(responses[0].Result) != `{"text":"[]"}` ||
+		responses[1].Error == nil || responses[1].Error.Code != codeInvalidRequest ||
+		string(responses[2].ID) != "2" || responses[2].Error == nil || responses[2].Error.Code != codeMethodNotFound {
+		t.Errorf("unexpected batch responses: %s", lines[0])
+	}
+
+	// An empty batch is an invalid request
+	var r response
+	if err := json.Unmarshal([]byte(lines[1]), &r); err != nil || r.Error == nil || r.Error.Code != codeInvalidRequest {
+		t.Errorf("expected an invalid request error, got %s", lines[1])
+	}
+}
+
+func TestServer_ContentLength(t *testing.T) {
+	server := NewServer()
+	body := `{"jsonrpc":"2.0","id":"a","method":"format","params":{"text":"[]"}}`
+	input := "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+
+	var output strings.Builder
+	if err := server.ServeConn(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"jsonrpc":"2.0","id":"a","result":{"text":"[]"}}`
+	if expected := "Content-Length: " + strconv.Itoa(len(want)) + "\r\n\r\n" + want; output.String() != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestServe_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "vtk.sock")
+	listener, err := ListenUnix(socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewServer().Serve(ctx, listener) }()
+
+	// A second daemon cannot take over the socket
+	if _, err := ListenUnix(socket); err == nil {
+		t.Error("expected an error for a socket in use")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"format","params":{"text":"{}"}}` + "\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != `{"jsonrpc":"2.0","id":1,"result":{"text":"{}"}}`+"\n" {
+		t.Errorf("unexpected response %q: %v", line, err)
+	}
+	conn.Close()
+
+	// Canceling closes connections still waiting for requests
+	idle, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer idle.Close()
+	conn, _ = net.Dial("unix", socket)
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":2,"method":"format","params":{"text":"{}"}}` + "\n"))
+	bufio.NewReader(conn).ReadString('\n')
+	defer conn.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected the server to stop when canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to stop with connections open")
+	}
+	if _, err := idle.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the idle connection to be closed")
+	}
+}
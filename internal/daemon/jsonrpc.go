@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000 // a method failed
+)
+
+// request is a JSON-RPC request. A request without an id is a notification,
+// which gets no response.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcError is the error object of a failed request.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// invalidParams returns the error for a request whose params are unusable.
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// successResponse and errorResponse are the two forms of a response, which
+// carries either a result or an error but never both.
+type successResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *rpcError       `json:"error"`
+}
+
+// readMessage reads the next message from r. A message is either framed by
+// a header block with a Content-Length, as in the Language Server Protocol,
+// or is a single line of JSON. framed reports which, so that the response
+// can be written the same way. It returns io.EOF at the end of the input.
+func readMessage(r *bufio.Reader) (body []byte, framed bool, err error) {
+	// Skip blank lines between messages
+	var line []byte
+	for {
+		line, err = r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	trimmed := bytes.TrimSpace(line)
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return trimmed, false, nil
+	}
+
+	// Read headers up to the blank line ending them
+	length := -1
+	for {
+		header := strings.TrimSpace(string(line))
+		if header == "" {
+			break
+		}
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, true, fmt.Errorf("invalid header: %q", header)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, true, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+		}
+
+		line, err = r.ReadBytes('\n')
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to read headers: %w", err)
+		}
+	}
+	if length < 0 {
+		return nil, true, fmt.Errorf("missing Content-Length header")
+	}
+
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, true, fmt.Errorf("failed to read message: %w", err)
+	}
+	return body, true, nil
+}
+
+// writeMessage writes a message body to w, framed with a Content-Length
+// header or as a single line.
+func writeMessage(w io.Writer, body []byte, framed bool) error {
+	var err error
+	if framed {
+		_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	} else {
+		_, err = fmt.Fprintf(w, "%s\n", body)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadMessage(t *testing.T) {
+	input := "Content-Length: 7\r\nContent-Type: application/json\r\n\r\n{\"a\":1}" +
+		"\n\n{\"b\":2}\n" +
+		"content-length: 2\n\n{}" +
+		"{\"c\":3}"
+	reader := bufio.NewReader(strings.NewReader(input))
+
+	want := []struct {
+		body   string
+		framed bool
+	}{
+		{`{"a":1}`, true},
+		{`{"b":2}`, false},
+		{`{}`, true},
+		{`{"c":3}`, false},
+	}
+	for _, w := range want {
+		body, framed, err := readMessage(reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(body) != w.body || framed != w.framed {
+			t.Errorf("expected %s (framed %v), got %s (framed %v)", w.body, w.framed, body, framed)
+		}
+	}
+	if _, _, err := readMessage(reader); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReadMessage_Errors(t *testing.T) {
+	inputs := []string{
+		"Content-Type: application/json\r\n\r\n{}",
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+		"garbage\r\n\r\n",
+	}
+	for _, input := range inputs {
+		if _, _, err := readMessage(bufio.NewReader(strings.NewReader(input))); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%q: expected a framing error, got %v", input, err)
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	writeMessage(&buf, []byte(`{"a":1}`), true)
+	writeMessage(&buf, []byte(`{"b":2}`), false)
+	if want := "Content-Length: 7\r\n\r\n{\"a\":1}{\"b\":2}\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
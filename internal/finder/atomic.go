@@ -0,0 +1,160 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stagedWrite is a change whose new content has been written to a temporary
+// file next to its target, ready to be renamed into place.
+type stagedWrite struct {
+	change Change
+	target string // Change.Path with symlinks resolved
+	temp   string
+}
+
+// ApplyChanges writes the new content of every change to disk. Each file is
+// replaced atomically by writing a temporary file in the same directory and
+// renaming it over the original, keeping the original's permissions and,
+// where possible, its owner.
+//
+// The batch is all-or-nothing: nothing is renamed until every temporary file
+// was written, and if a rename fails the files already replaced are restored
+// to their old content. A file that can no longer be read, or whose content
+// no longer matches Change.Old, fails the batch before anything is written.
+func ApplyChanges(changes []Change) error {
+	var staged []stagedWrite
+	cleanup := func() {
+		for _, s := range staged {
+			os.Remove(s.temp)
+		}
+	}
+
+	for _, change := range changes {
+		s, err := stageChange(change)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		staged = append(staged, s)
+	}
+
+	for i, s := range staged {
+		if err := os.Rename(s.temp, s.target); err != nil {
+			cleanup()
+			if rbErr := rollback(staged[:i]); rbErr != nil {
+				return fmt.Errorf("failed to write %s: %w (rollback failed: %v)", s.change.Path, err, rbErr)
+			}
+			return fmt.Errorf("failed to write %s: %w", s.change.Path, err)
+		}
+	}
+	return nil
+}
+
+// stageChange checks that the target still holds the planned old content
+// and writes the new content to a temporary file beside it.
+func stageChange(change Change) (stagedWrite, error) {
+	// Replace the file a symlink points to rather than the link itself
+	target, err := filepath.EvalSymlinks(change.Path)
+	if err != nil {
+		return stagedWrite{}, fmt.Errorf("failed to read %s: %w", change.Path, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return stagedWrite{}, fmt.Errorf("failed to read %s: %w", change.Path, err)
+	}
+	current, err := os.ReadFile(change.Path)
+	if err != nil {
+		return stagedWrite{}, fmt.Errorf("failed to read %s: %w", change.Path, err)
+	}
+	if !bytes.Equal(current, change.Old) {
+		return stagedWrite{}, fmt.Errorf("%s was modified since it was read", change.Path)
+	}
+
+	temp, err := writeTemp(target, change.New, info)
+	if err != nil {
+		return stagedWrite{}, fmt.Errorf("failed to write %s: %w", change.Path, err)
+	}
+	return stagedWrite{change: change, target: target, temp: temp}, nil
+}
+
+// writeTemp writes data to a new temporary file in the directory of path
+// with the mode and owner of info, and returns the temporary file's name.
+func writeTemp(path string, data []byte, info os.FileInfo) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".vtk-*")
+	if err != nil {
+		return "", err
+	}
+	temp := file.Name()
+
+	err = func() error {
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+		if err := file.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+		// Keeping the owner needs privileges we may not have, so a failure
+		// to do so leaves the file owned by the current user
+		chownLike(file, info)
+		if err := file.Sync(); err != nil {
+			return err
+		}
+		return file.Close()
+	}()
+	if err != nil {
+		file.Close()
+		os.Remove(temp)
+		return "", err
+	}
+	return temp, nil
+}
+
+// writeFileAtomic replaces the file at path with data, creating it with
+// permissions perm if needed. Readers see either the old or the new
+// content, never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".vtk-*")
+	if err != nil {
+		return err
+	}
+	temp := file.Name()
+	defer os.Remove(temp)
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// rollback restores the old content of changes that were already applied.
+func rollback(applied []stagedWrite) error {
+	var firstErr error
+	for _, s := range applied {
+		info, err := os.Stat(s.target)
+		if err == nil {
+			var temp string
+			temp, err = writeTemp(s.target, s.change.Old, info)
+			if err == nil {
+				err = os.Rename(temp, s.target)
+				if err != nil {
+					os.Remove(temp)
+				}
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to restore %s: %w", s.change.Path, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,120 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyChanges_PreservesModeAndNewline(t *testing.T) {
+	tempDir := t.TempDir()
+	script := filepath.Join(tempDir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho hello\n"), 0755); err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+
+	if _, err := Replace(tempDir, "hello", "goodbye"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, _ := os.ReadFile(script)
+	if string(content) != "#!/bin/sh\necho goodbye\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatalf("failed to stat script: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected mode 0755, got %v", info.Mode().Perm())
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(tempDir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".vtk-") {
+			t.Errorf("unexpected temporary file %s", entry.Name())
+		}
+	}
+}
+
+func TestApplyChanges_FollowsSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "target.txt")
+	link := filepath.Join(tempDir, "link.txt")
+	os.WriteFile(target, []byte("hello\n"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	err := ApplyChanges([]Change{{Path: link, Old: []byte("hello\n"), New: []byte("goodbye\n")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected %s to remain a symlink", link)
+	}
+	content, _ := os.ReadFile(target)
+	if string(content) != "goodbye\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestApplyChanges_AllOrNothing(t *testing.T) {
+	tempDir := t.TempDir()
+	first := filepath.Join(tempDir, "first.txt")
+	second := filepath.Join(tempDir, "second.txt")
+	os.WriteFile(first, []byte("hello\n"), 0644)
+	os.WriteFile(second, []byte("edited since planning\n"), 0644)
+
+	changes := []Change{
+		{Path: first, Old: []byte("hello\n"), New: []byte("goodbye\n")},
+		{Path: second, Old: []byte("hello\n"), New: []byte("goodbye\n")},
+	}
+	err := ApplyChanges(changes)
+	if err == nil || !strings.Contains(err.Error(), "modified since it was read") {
+		t.Fatalf("expected modification error, got %v", err)
+	}
+
+	content, _ := os.ReadFile(first)
+	if string(content) != "hello\n" {
+		t.Errorf("expected first file to be untouched, got %q", content)
+	}
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 2 {
+		t.Errorf("expected temporary files to be removed, found %d entries", len(entries))
+	}
+
+	// A missing file fails the batch the same way
+	changes[1] = Change{Path: filepath.Join(tempDir, "missing.txt"), Old: []byte("x"), New: []byte("y")}
+	if err := ApplyChanges(changes); err == nil || !strings.Contains(err.Error(), "failed to read") {
+		t.Fatalf("expected read error, got %v", err)
+	}
+}
+
+func TestApplyChanges_Rollback(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "file.txt")
+	os.WriteFile(path, []byte("hello\n"), 0600)
+
+	s, err := stageChange(Change{Path: path, Old: []byte("hello\n"), New: []byte("goodbye\n")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Rename(s.temp, s.target); err != nil {
+		t.Fatalf("failed to apply staged write: %v", err)
+	}
+
+	if err := rollback([]stagedWrite{s}); err != nil {
+		t.Fatalf("unexpected rollback error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "hello\n" {
+		t.Errorf("expected original content after rollback, got %q", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600 after rollback, got %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,124 @@
+package finder
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache keeps the ignore rules and indexes that searches load in memory
+// between searches, for long-running processes such as an editor daemon.
+// Pass the same Cache in the Options of every search to share it.
+//
+// Nothing cached is trusted blindly. Ignore files are compiled again once
+// their modification time or size changes, and index entries are checked
+// against their files whenever a search uses them, as they are on disk.
+// Trees without a symbol index on disk get one kept only in memory, so
+// repeated symbol searches only parse the files that changed. A Cache is
+// safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	ignores map[string]*cachedIgnoreFile // by ignore file path
+	symbols map[string]*symbolIndex      // by state root
+	texts   map[string]*textIndex        // by state root
+}
+
+// cachedIgnoreFile is a compiled ignore file and the version of the file it
+// was compiled from.
+type cachedIgnoreFile struct {
+	base    string
+	modTime time.Time
+	size    int64
+	loaded  time.Time
+	rules   *ignoreRules
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{
+		ignores: make(map[string]*cachedIgnoreFile),
+		symbols: make(map[string]*symbolIndex),
+		texts:   make(map[string]*textIndex),
+	}
+}
+
+// ignoreRules is like loadIgnoreRules but reuses the rules compiled from an
+// unchanged file. A nil cache always loads the file.
+func (c *Cache) ignoreRules(base string, path string) *ignoreRules {
+	if c == nil {
+		return loadIgnoreRules(base, path)
+	}
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.ignores, path)
+		c.mu.Unlock()
+		return nil
+	}
+
+	// Files modified just before they were loaded may have changed again
+	// without their modification time showing it
+	c.mu.Lock()
+	cached := c.ignores[path]
+	c.mu.Unlock()
+	if cached != nil && cached.base == base && cached.modTime.Equal(info.ModTime()) &&
+		cached.size == info.Size() && cached.loaded.Sub(info.ModTime()) >= racyWindow {
+		return cached.rules
+	}
+
+	loaded := time.Now()
+	rules := loadIgnoreRules(base, path)
+	if rules == nil {
+		return nil
+	}
+	c.mu.Lock()
+	c.ignores[path] = &cachedIgnoreFile{base: base, modTime: info.ModTime(), size: info.Size(), loaded: loaded, rules: rules}
+	c.mu.Unlock()
+	return rules
+}
+
+// symbolIndex returns the symbol index for a search of dir. Without a cache
+// it is the index on disk, or nil if none was built. A cache keeps the
+// index of each tree in memory, starting from the one on disk and saving
+// back to it if there is one.
+func (c *Cache) symbolIndex(dir string) *symbolIndex {
+	if c == nil {
+		return openSymbolIndex(dir)
+	}
+
+	root := stateRoot(dir)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := c.symbols[root]
+	if index == nil {
+		index = loadSymbolIndex(dir)
+		if _, err := os.Stat(index.path); err != nil {
+			index.memoryOnly = true
+		}
+		c.symbols[root] = index
+	}
+	return index
+}
+
+// textIndex returns the text index for a search of dir, or nil if none was
+// built. A cache keeps a text index in memory once it was read from disk.
+func (c *Cache) textIndex(dir string) *textIndex {
+	if c == nil {
+		return openTextIndex(dir)
+	}
+
+	root := stateRoot(dir)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := c.texts[root]
+	if index == nil {
+		if index = openTextIndex(dir); index != nil {
+			c.texts[root] = index
+		}
+	}
+	return index
+}
@@ -0,0 +1,117 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_IgnoreRules(t *testing.T) {
+	tempDir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, filepath.Join(tempDir, "a.txt"), "needle\n", old)
+	writeAged(t, filepath.Join(tempDir, "b.txt"), "needle\n", old)
+	writeAged(t, filepath.Join(tempDir, ".gitignore"), "a.txt\n", old)
+
+	cache := NewCache()
+	opts := Options{Cache: cache}
+	count := func() int {
+		t.Helper()
+		results, err := FindWithOptions(tempDir, "needle", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return len(results)
+	}
+
+	if got := count(); got != 1 {
+		t.Fatalf("expected 1 match, got %d", got)
+	}
+	cached := cache.ignores[filepath.Join(tempDir, ".gitignore")]
+	if got := count(); got != 1 || cache.ignores[filepath.Join(tempDir, ".gitignore")] != cached {
+		t.Fatalf("expected the compiled .gitignore to be reused, got %d matches", got)
+	}
+
+	// A changed .gitignore is compiled again
+	writeAged(t, filepath.Join(tempDir, ".gitignore"), "*.txt\n", old.Add(time.Minute))
+	if got := count(); got != 0 {
+		t.Errorf("expected the new rules to ignore both files, got %d matches", got)
+	}
+
+	// So is a removed one
+	os.Remove(filepath.Join(tempDir, ".gitignore"))
+	if got := count(); got != 2 {
+		t.Errorf("expected no file to be ignored, got %d matches", got)
+	}
+}
+
+func TestCache_SymbolIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, path, "package main\n\nfunc Alpha() {}\n", old)
+
+	cache := NewCache()
+	names := func() string {
+		t.Helper()
+		results, err := FindSymbolsWithOptions(tempDir, "^[A-Z]", Options{Cache: cache})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Match)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := names(); got != "Alpha" {
+		t.Fatalf("unexpected symbols: %s", got)
+	}
+	index := cache.symbols[stateRoot(tempDir)]
+	if index == nil || !index.memoryOnly || index.parsed != 1 {
+		t.Fatalf("expected an in-memory index with one parsed file, got %+v", index)
+	}
+
+	// Unchanged files are not parsed again
+	names()
+	if index.parsed != 1 {
+		t.Errorf("expected no more files to be parsed, got %d", index.parsed)
+	}
+
+	// Changed ones are
+	writeAged(t, path, "package main\n\nfunc Beta() {}\n", old.Add(time.Minute))
+	if got := names(); got != "Beta" {
+		t.Errorf("unexpected symbols after the change: %s", got)
+	}
+
+	// An in-memory index is never written to disk
+	if _, err := os.Stat(filepath.Join(tempDir, stateDirName)); !os.IsNotExist(err) {
+		t.Errorf("expected no state directory, got %v", err)
+	}
+}
+
+func TestCache_SavesBuiltIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, path, "package main\n\nfunc Alpha() {}\n", old)
+
+	if _, err := BuildSymbolIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A change found through the cache reaches the index on disk
+	cache := NewCache()
+	writeAged(t, path, "package main\n\nfunc Beta() {}\n", old.Add(time.Minute))
+	if _, err := FindSymbolsWithOptions(tempDir, "Beta", Options{Cache: cache}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	index := loadSymbolIndex(tempDir)
+	entry := index.data.Files["main.go"]
+	if entry == nil || len(entry.Symbols) == 0 || entry.Symbols[len(entry.Symbols)-1].Name != "Beta" {
+		t.Errorf("expected the saved index to hold Beta, got %+v", entry)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package finder
+
+import "os"
+
+// chownLike is a no-op on platforms without Unix file ownership.
+func chownLike(file *os.File, info os.FileInfo) {}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package finder
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike gives file the owner and group recorded in info, ignoring
+// failures.
+func chownLike(file *os.File, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		file.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,109 @@
+package finder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParsePosition splits a file:line:column position, as taken by
+// IdentifierAt. The file name itself may contain colons.
+func ParsePosition(position string) (path string, line int, column int, ok bool) {
+	parts := strings.Split(position, ":")
+	if len(parts) < 3 {
+		return "", 0, 0, false
+	}
+	n := len(parts)
+	line, err := strconv.Atoi(parts[n-2])
+	if err != nil || line < 1 {
+		return "", 0, 0, false
+	}
+	column, err = strconv.Atoi(parts[n-1])
+	if err != nil || column < 0 {
+		return "", 0, 0, false
+	}
+	return strings.Join(parts[:n-2], ":"), line, column, true
+}
+
+// IdentifierAt returns the identifier at the 1-based line and 0-based byte
+// column of the code file at path, as in the positions vtk prints. A column
+// just past the end of an identifier also selects it, since that is where
+// an editor's cursor rests after typing it. Comments and strings hold no
+// identifiers.
+func IdentifierAt(path string, line int, column int) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	for _, ident := range codeIdentifiers(path, content) {
+		if ident.line == line && ident.column <= column && column <= ident.column+len(ident.name) {
+			return ident.name, nil
+		}
+	}
+	return "", fmt.Errorf("no identifier at %s:%d:%d", path, line, column)
+}
+
+// FindDefinitions returns the symbols named name in the repository that
+// contains the file at path or, outside a repository, in the nearest
+// directory above it with a symbol index, or else the file's directory.
+// The definitions in the file itself come first, then those in its
+// directory, which for Go is its package, then all others, each group in
+// walk order. Paths are relative to path as given, like those of a search
+// of its directory. Symbol searches reuse the symbol index when one was
+// built, and opts.MaxCount limits the ranked results.
+func FindDefinitions(path string, name string, opts Options) ([]Result, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	root := stateRoot(filepath.Dir(absPath))
+
+	// SQL names are case-insensitive, so match loosely and filter below
+	maxCount := opts.MaxCount
+	opts.MaxCount = 0
+	results, err := FindSymbolsWithOptions(root, "(?i)^"+regexp.QuoteMeta(name)+"$", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []Result
+	for _, result := range results {
+		symbolName := result.Match[strings.LastIndex(result.Match, ".")+1:]
+		if symbolName == name || (filepath.Ext(result.Path) == ".sql" && strings.EqualFold(symbolName, name)) {
+			definitions = append(definitions, result)
+		}
+	}
+
+	// Rank by closeness to the file asking
+	tier := func(result Result) int {
+		switch {
+		case result.Path == absPath:
+			return 0
+		case filepath.Dir(result.Path) == filepath.Dir(absPath):
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(definitions, func(i, j int) bool {
+		return tier(definitions[i]) < tier(definitions[j])
+	})
+
+	if maxCount > 0 && len(definitions) > maxCount {
+		definitions = definitions[:maxCount]
+	}
+
+	// Express the paths the way path is
+	dir := filepath.Dir(absPath)
+	for i := range definitions {
+		if rel, err := filepath.Rel(dir, definitions[i].Path); err == nil {
+			definitions[i].Path = filepath.Join(filepath.Dir(path), rel)
+		}
+	}
+	return definitions, nil
+}
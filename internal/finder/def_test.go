@@ -0,0 +1,107 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIdentifierAt(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	os.WriteFile(path, []byte("package main\n\n// run it\nfunc main() { run(\"x\") }\n"), 0644)
+
+	tests := []struct {
+		line, column int
+		want         string
+		wantErr      bool
+	}{
+		{4, 5, "main", false},
+		{4, 14, "run", false},
+		{4, 17, "run", false}, // Just past the end
+		{4, 10, "", true},     // Between identifiers
+		{4, 19, "", true},     // Inside a string
+		{3, 4, "", true},      // Inside a comment
+		{9, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := IdentifierAt(path, tt.line, tt.column)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%d:%d: expected an error, got %q", tt.line, tt.column, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d:%d: unexpected error: %v", tt.line, tt.column, err)
+		} else if got != tt.want {
+			t.Errorf("%d:%d: expected %q, got %q", tt.line, tt.column, tt.want, got)
+		}
+	}
+}
+
+func TestFindDefinitions(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, ".git"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "server"), 0755)
+	os.MkdirAll(filepath.Join(tempDir, "client"), 0755)
+
+	files := map[string]string{
+		"server/main.go":   "package server\n\nfunc Start() { Open() }\n\nfunc Open() {}\n",
+		"server/conn.go":   "package server\n\ntype Conn struct{}\n\nfunc (c *Conn) Open() {}\n",
+		"client/client.py": "def Open():\n    pass\n\ndef open():\n    pass\n",
+		"schema.sql":       "CREATE TABLE OPEN (id int);\n",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+	}
+
+	from := filepath.Join(tempDir, "server", "main.go")
+	name, err := IdentifierAt(from, 3, 15)
+	if err != nil || name != "Open" {
+		t.Fatalf("expected Open under the cursor, got %q, %v", name, err)
+	}
+
+	results, err := FindDefinitions(from, name, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		filepath.Join(tempDir, "server", "main.go") + ":5:5: func Open",
+		filepath.Join(tempDir, "server", "conn.go") + ":5:15: method Conn.Open",
+		filepath.Join(tempDir, "client", "client.py") + ":1:4: function Open",
+		filepath.Join(tempDir, "schema.sql") + ":1:13: table OPEN",
+	}
+	output := FormatEmacsOutput(results)
+	if expected := strings.Join(want, "\n") + "\n"; output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	// The limit applies after ranking
+	results, err = FindDefinitions(from, name, Options{MaxCount: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != from {
+		t.Errorf("expected only the same-file definition, got %+v", results)
+	}
+
+	// Paths are relative to the path as given, like those of other searches
+	oldDir, _ := os.Getwd()
+	defer os.Chdir(oldDir)
+	os.Chdir(filepath.Join(tempDir, "server"))
+	results, err = FindDefinitions("main.go", name, Options{MaxCount: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = []string{
+		"main.go:5:5: func Open",
+		"conn.go:5:15: method Conn.Open",
+		filepath.Join("..", "client", "client.py") + ":1:4: function Open",
+	}
+	if output, expected := FormatEmacsOutput(results), strings.Join(want, "\n")+"\n"; output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package finder
+
+import "os"
+
+// deviceID reports no device on platforms without Unix device numbers, so
+// walks never detect crossing into another file system.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
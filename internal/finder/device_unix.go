@@ -0,0 +1,16 @@
+//go:build unix
+
+package finder
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceID returns the device holding the file described by info.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), true
+	}
+	return 0, false
+}
@@ -0,0 +1,241 @@
+package finder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each hunk.
+const diffContext = 3
+
+// diffOp is one step of an edit script turning old lines into new lines.
+type diffOp struct {
+	kind byte // ' ' for an unchanged line, '-' for a deletion, '+' for an insertion
+	text string
+}
+
+// UnifiedDiff returns a unified diff of the change, or "" when its old and
+// new content are identical.
+func UnifiedDiff(change Change) string {
+	oldLines := splitLines(string(change.Old))
+	newLines := splitLines(string(change.New))
+	ops := diffLines(oldLines, newLines)
+
+	var output strings.Builder
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			oldLine++
+			newLine++
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are within twice the context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+
+		// Include leading and trailing context
+		lead := min(diffContext, start)
+		trail := 0
+		for end+trail < len(ops) && trail < diffContext && ops[end+trail].kind == ' ' {
+			trail++
+		}
+		hunk := ops[start-lead : end+trail]
+
+		oldStart, newStart := oldLine-lead, newLine-lead
+		oldCount, newCount := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		if output.Len() == 0 {
+			fmt.Fprintf(&output, "--- %s\n+++ %s\n", change.Path, change.Path)
+		}
+		fmt.Fprintf(&output, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range hunk {
+			output.WriteByte(op.kind)
+			output.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				output.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		// Advance line counters past the hunk body
+		for _, op := range ops[start : end+trail] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		start = end + trail
+	}
+
+	return output.String()
+}
+
+// hunkRange formats the start,count part of a hunk header.
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		// An empty range names the line before it
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits text into lines, keeping each line's newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes an edit script from a to b. Replacements rarely add or
+// remove lines, so when both sides have the same length lines are paired
+// up directly; otherwise a shortest edit script is found with Myers'
+// O(ND) algorithm.
+func diffLines(a []string, b []string) []diffOp {
+	var ops []diffOp
+	if len(a) == len(b) {
+		for i := 0; i < len(a); {
+			if a[i] == b[i] {
+				ops = append(ops, diffOp{' ', a[i]})
+				i++
+				continue
+			}
+			// Show a run of changed lines as deletions followed by insertions
+			j := i
+			for j < len(a) && a[j] != b[j] {
+				j++
+			}
+			for _, line := range a[i:j] {
+				ops = append(ops, diffOp{'-', line})
+			}
+			for _, line := range b[i:j] {
+				ops = append(ops, diffOp{'+', line})
+			}
+			i = j
+		}
+		return ops
+	}
+
+	// Trim the common prefix and suffix before running Myers
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff computes a shortest edit script from a to b.
+func myersDiff(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+2)
+
+	// trace[d] holds v[-d..d] after round d, enough to recover the path
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // move right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(trace, a, b)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+	return nil
+}
+
+// backtrack walks the Myers trace backwards to build the edit script.
+func backtrack(trace [][]int, a []string, b []string) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d-1] covers diagonals -(d-1)..d-1
+		prev := func(k int) int { return trace[d-1][k+d-1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+
+	// Reverse into forward order
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
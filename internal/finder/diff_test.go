@@ -0,0 +1,83 @@
+package finder
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	new := strings.Replace(strings.Replace(old, "two", "TWO", 1), "twelve", "TWELVE", 1)
+
+	want := "--- file.txt\n+++ file.txt\n" +
+		"@@ -1,5 +1,5 @@\n one\n-two\n+TWO\n three\n four\n five\n" +
+		"@@ -9,4 +9,4 @@\n nine\n ten\n eleven\n-twelve\n+TWELVE\n"
+
+	got := UnifiedDiff(Change{Path: "file.txt", Old: []byte(old), New: []byte(new)})
+	if got != want {
+		t.Errorf("diff mismatch:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiff_MergesNearbyHunksAndMarksMissingNewline(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh"
+	new := "A\nb\nc\nd\ne\nf\ng\nH"
+
+	want := "--- f\n+++ f\n" +
+		"@@ -1,8 +1,8 @@\n-a\n+A\n b\n c\n d\n e\n f\n g\n" +
+		"-h\n\\ No newline at end of file\n+H\n\\ No newline at end of file\n"
+
+	got := UnifiedDiff(Change{Path: "f", Old: []byte(old), New: []byte(new)})
+	if got != want {
+		t.Errorf("diff mismatch:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+
+	if diff := UnifiedDiff(Change{Path: "f", Old: []byte(old), New: []byte(old)}); diff != "" {
+		t.Errorf("expected empty diff for identical content, got %q", diff)
+	}
+}
+
+func TestUnifiedDiff_InsertedLines(t *testing.T) {
+	old := "a\nb\nc\n"
+	new := "a\nb1\nb2\nc\n"
+
+	want := "--- f\n+++ f\n@@ -1,3 +1,4 @@\n a\n-b\n+b1\n+b2\n c\n"
+	got := UnifiedDiff(Change{Path: "f", Old: []byte(old), New: []byte(new)})
+	if got != want {
+		t.Errorf("diff mismatch:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+
+	want = "--- f\n+++ f\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	got = UnifiedDiff(Change{Path: "f", Old: nil, New: []byte("x\ny\n")})
+	if got != want {
+		t.Errorf("diff mismatch:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestDiffLines_Reconstructs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				gotA = append(gotA, op.text)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.text)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("edit script does not reproduce inputs %q and %q", a, b)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileFilter selects the paths a walk yields by the filtering fields of
+// Options, on top of ignore rules, and holds the controls walkTree applies.
+// A nil filter selects every path and leaves the controls at their
+// defaults.
+type fileFilter struct {
+	types        []string // globs of Options.Types
+	excludeTypes []string // globs of Options.ExcludeTypes
+
+	include *ignoreRules // Options.Include, relative to the search directory
+	exclude *ignoreRules // Options.Exclude, relative to the search directory
+
+	// Walk controls, from the Options fields of the same names
+	hidden         bool
+	followSymlinks bool
+	maxDepth       int
+	maxFileSize    int64
+	oneFileSystem  bool
+}
+
+// newFileFilter builds the filter for a search of dir with opts, or returns
+// nil when opts neither filters paths nor changes the walk controls.
+func newFileFilter(dir string, opts Options) (*fileFilter, error) {
+	if len(opts.Types) == 0 && len(opts.ExcludeTypes) == 0 && len(opts.Include) == 0 && len(opts.Exclude) == 0 &&
+		!opts.Hidden && !opts.FollowSymlinks && opts.MaxDepth <= 0 && opts.MaxFileSize <= 0 && !opts.OneFileSystem {
+		return nil, nil
+	}
+
+	f := &fileFilter{
+		hidden:         opts.Hidden,
+		followSymlinks: opts.FollowSymlinks,
+		maxDepth:       max(opts.MaxDepth, 0),
+		maxFileSize:    max(opts.MaxFileSize, 0),
+		oneFileSystem:  opts.OneFileSystem,
+	}
+	if len(opts.Types) > 0 || len(opts.ExcludeTypes) > 0 {
+		types, err := FileTypes()
+		if err != nil {
+			return nil, err
+		}
+		if f.types, err = resolveFileTypes(types, opts.Types); err != nil {
+			return nil, err
+		}
+		if f.excludeTypes, err = resolveFileTypes(types, opts.ExcludeTypes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Walks yield paths joined to dir, so rules based at dir match them
+	// whether dir is relative or absolute. A file searched on its own is
+	// matched by its name, relative to its directory.
+	base := dir
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		base = filepath.Dir(dir)
+	}
+	if len(opts.Include) > 0 {
+		f.include = compileIgnoreRules(base, opts.Include)
+	}
+	if len(opts.Exclude) > 0 {
+		f.exclude = compileIgnoreRules(base, opts.Exclude)
+	}
+	return f, nil
+}
+
+// matchFile reports whether the file at path is selected.
+func (f *fileFilter) matchFile(path string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.types) > 0 && !matchesAnyGlob(path, f.types) {
+		return false
+	}
+	if matchesAnyGlob(path, f.excludeTypes) {
+		return false
+	}
+	return f.included(path, false) && !f.excluded(path, false)
+}
+
+// skipDir reports whether the walk should skip the directory at path and
+// everything below it.
+func (f *fileFilter) skipDir(path string) bool {
+	return f != nil && f.excluded(path, true)
+}
+
+// included reports whether path matches an include glob, or true when
+// there are none.
+func (f *fileFilter) included(path string, isDir bool) bool {
+	if f == nil || f.include == nil {
+		return true
+	}
+	_, included := f.include.match(path, isDir)
+	return included
+}
+
+// excluded reports whether path matches an exclude glob.
+func (f *fileFilter) excluded(path string, isDir bool) bool {
+	if f == nil || f.exclude == nil {
+		return false
+	}
+	_, excluded := f.exclude.match(path, isDir)
+	return excluded
+}
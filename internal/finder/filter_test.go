@@ -0,0 +1,145 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPathFilters(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"main.go":                      "package main // needle\n",
+		"main_test.go":                 "package main // needle\n",
+		"internal/app/app.go":          "package app // needle\n",
+		"internal/app/app_test.go":     "package app // needle\n",
+		"internal/testdata/needle.txt": "needle\n",
+		"vendor/lib/lib.go":            "package lib // needle\n",
+	})
+
+	relPaths := func(results []Result) string {
+		var paths []string
+		for _, result := range results {
+			rel, _ := filepath.Rel(tempDir, result.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		sort.Strings(paths)
+		return strings.Join(paths, ",")
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		find string
+		dirs string
+	}{
+		{
+			name: "exclude at any depth",
+			opts: Options{Exclude: []string{"**/*_test.go", "vendor"}},
+			find: "internal/app/app.go,internal/testdata/needle.txt,main.go",
+			dirs: "internal,internal/app,internal/testdata",
+		},
+		{
+			name: "include below a directory",
+			opts: Options{Include: []string{"internal/**"}},
+			find: "internal/app/app.go,internal/app/app_test.go,internal/testdata/needle.txt",
+			dirs: "internal,internal/app,internal/testdata",
+		},
+		{
+			name: "include and exclude",
+			opts: Options{Include: []string{"*.go"}, Exclude: []string{"*_test.go", "testdata/"}},
+			find: "internal/app/app.go,main.go,vendor/lib/lib.go",
+			dirs: "",
+		},
+		{
+			name: "anchored exclude",
+			opts: Options{Exclude: []string{"/main*.go"}},
+			find: "internal/app/app.go,internal/app/app_test.go,internal/testdata/needle.txt,vendor/lib/lib.go",
+			dirs: "internal,internal/app,internal/testdata,vendor,vendor/lib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := FindWithOptions(tempDir, "needle", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.find {
+				t.Errorf("find: expected %s, got %s", tt.find, got)
+			}
+
+			results, err = collect(func(emit func(Result) error) error {
+				return GlobDirectoriesStream(t.Context(), tempDir, ".", tt.opts, emit)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.dirs {
+				t.Errorf("dirs: expected %s, got %s", tt.dirs, got)
+			}
+
+			changes, err := PlanReplace(tempDir, "needle", "pin", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var changed []Result
+			for _, change := range changes {
+				changed = append(changed, Result{Path: change.Path})
+			}
+			if got := relPaths(changed); got != tt.find {
+				t.Errorf("replace: expected %s, got %s", tt.find, got)
+			}
+		})
+	}
+}
+
+func TestPathFilters_RelativeDir(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"src/a.go":      "needle\n",
+		"src/gen/b.go":  "needle\n",
+		"src/gen/c.txt": "needle\n",
+	})
+
+	oldDir, _ := os.Getwd()
+	defer os.Chdir(oldDir)
+	os.Chdir(tempDir)
+
+	// Globs are relative to the searched directory, not the working one
+	results, err := FindWithOptions("src", "needle", Options{Include: []string{"gen/*.go"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join("src", "gen", "b.go") {
+		t.Errorf("expected only src/gen/b.go, got %+v", results)
+	}
+}
+
+func TestPathFilters_FileTarget(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "internal", "x", "a.go")
+	writeTree(t, tempDir, map[string]string{"internal/x/a.go": "package x // needle\n"})
+
+	// A file searched on its own is matched by its name
+	tests := []struct {
+		opts Options
+		want int
+	}{
+		{opts: Options{Include: []string{"*.go"}}, want: 1},
+		{opts: Options{Include: []string{"*.txt"}}, want: 0},
+		{opts: Options{Exclude: []string{"a.go"}}, want: 0},
+		{opts: Options{Exclude: []string{"*_test.go"}}, want: 1},
+	}
+	for _, tt := range tests {
+		results, err := FindWithOptions(path, "needle", tt.opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("%+v: expected %d results, got %+v", tt.opts, tt.want, results)
+		}
+	}
+}
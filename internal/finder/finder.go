@@ -4,13 +4,14 @@ package finder
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
-
-	ignore "github.com/sabhiram/go-gitignore"
 )
 
 // Result represents a single match in a file.
@@ -19,85 +20,199 @@ type Result struct {
 	Line   int
 	Column int
 	Match  string
+
+	// EndLine and EndColumn locate the byte just past the match, and
+	// Matched is the matched text itself. They are set for text searches
+	// and replacements; EndLine differs from Line only in multiline mode.
+	EndLine   int
+	EndColumn int
+	Matched   string
+
+	// Before and After hold the lines surrounding the match when context
+	// was requested. When the context windows of nearby matches overlap,
+	// each line is attached to only one result.
+	Before []ContextLine
+	After  []ContextLine
+
+	// Kind is the kind of symbol a symbol search matched, such as
+	// "function" or "class". It is empty for text searches.
+	Kind string
+}
+
+// ContextLine is a non-matching line printed around a match.
+type ContextLine struct {
+	Line int
+	Text string
+}
+
+// Options controls how the finder walks and searches a directory tree.
+// The zero value is ready to use.
+type Options struct {
+	// Workers is the number of files searched concurrently.
+	// Zero or a negative value uses one worker per CPU.
+	Workers int
+
+	// BeforeContext and AfterContext are the number of lines of context
+	// to collect before and after each text match.
+	BeforeContext int
+	AfterContext  int
+
+	// MaxCount stops a search once this many results were found.
+	// Zero means no limit.
+	MaxCount int
+
+	// AllMatches reports every occurrence on a line as its own result
+	// instead of only the first.
+	AllMatches bool
+
+	// Multiline matches the pattern against whole-file content so that
+	// matches may span lines. In this mode ^ and $ match at line boundaries.
+	Multiline bool
+
+	// Kinds restricts symbol searches to symbols of these kinds, such as
+	// "function" or "class". Empty means every kind.
+	Kinds []string
+
+	// Types restricts searches and replacements to files of these named
+	// types, such as "go" or "md", and ExcludeTypes skips files of these
+	// types. FileTypes lists the names. Directory searches are not
+	// filtered by type.
+	Types        []string
+	ExcludeTypes []string
+
+	// Include restricts searches and replacements to the paths matching
+	// any of these globs, and Exclude skips the paths matching any of them
+	// along with everything below excluded directories. The globs use
+	// gitignore syntax relative to the searched directory, so "*_test.go"
+	// matches at any depth and "internal/**" only below internal. A file
+	// searched on its own is matched relative to its directory.
+	Include []string
+	Exclude []string
+
+	// Hidden includes the hidden files and directories, whose names start
+	// with a dot, that searches skip by default. Git directories are
+	// skipped either way.
+	Hidden bool
+
+	// FollowSymlinks walks into symlinked directories, except those leading
+	// back to a directory being walked. By default symlinked directories
+	// are skipped, while symlinked files are searched.
+	FollowSymlinks bool
+
+	// MaxDepth limits how far below the searched directory a walk goes;
+	// 1 only reaches the directory's own entries. Zero means no limit.
+	MaxDepth int
+
+	// MaxFileSize skips files larger than this many bytes. Zero means no
+	// limit.
+	MaxFileSize int64
+
+	// OneFileSystem skips directories on other file systems than the
+	// searched directory, such as mount points.
+	OneFileSystem bool
+
+	// Cache, when set, keeps ignore rules and indexes in memory for the
+	// next search that uses the same Cache.
+	Cache *Cache
 }
 
 // Find searches for a pattern in all text files under the given directory,
 // respecting .gitignore rules.
 func Find(dir string, pattern string) ([]Result, error) {
+	return FindWithOptions(dir, pattern, Options{})
+}
+
+// FindWithOptions is like Find but searches files on a pool of opts.Workers
+// goroutines. Results come in walk order, with the entries of each
+// directory in lexical order, then by line, then by column.
+func FindWithOptions(dir string, pattern string, opts Options) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return FindStream(context.Background(), dir, pattern, opts, emit)
+	})
+}
+
+// FindStream is like FindWithOptions but passes each result to fn as soon as
+// it is available instead of collecting them. It stops when ctx is cancelled,
+// returning ctx.Err(), when fn returns an error, which is returned as is, or
+// once opts.MaxCount results were delivered.
+func FindStream(ctx context.Context, dir string, pattern string, opts Options, fn func(Result) error) error {
 	// Compile regex pattern
+	if opts.Multiline {
+		pattern = "(?m)" + pattern
+	}
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		return fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("directory does not exist: %s", dir)
+		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			// If we can't parse gitignore, continue without it
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return err
 	}
 
-	var results []Result
-
-	// Walk the directory tree
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip files we can't access
+	// Only read the files the text index cannot rule out
+	mayMatch := func(path string) bool { return true }
+	if index := opts.Cache.textIndex(dir); index != nil {
+		if query := regexpTrigramQuery(pattern); query.op != trigramAll {
+			mayMatch = func(path string) bool { return index.mayMatch(path, query) }
+			// The index is only a cache, so failing to update it does not
+			// fail the search
+			defer index.save()
 		}
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			// Check if directory should be ignored
-			if gi != nil {
-				relPath, _ := filepath.Rel(dir, path)
-				if relPath != "." && gi.MatchesPath(relPath) {
-					return filepath.SkipDir
-				}
-			}
-			return nil
-		}
-
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-
-		// Check if file is ignored
-		if gi != nil && gi.MatchesPath(relPath) {
-			return nil
+	// Search the files in parallel as the walk finds them
+	return streamFiles(ctx, opts, walkTextFiles(dir, opts.Cache, filter), func(path string) ([]Result, error) {
+		if !mayMatch(path) {
+			return nil, nil
 		}
 
 		// Skip binary files
 		if IsBinaryFile(path) {
-			return nil
+			return nil, nil
 		}
-
-		// Search in file
-		matches, err := searchFile(path, re)
-		if err != nil {
-			return nil // Skip files we can't read
+		if opts.Multiline {
+			return searchFileMultiline(path, re, opts)
 		}
+		return searchFile(path, re, opts)
+	}, fn)
+}
 
-		results = append(results, matches...)
-		return nil
-	})
+// walkTextFiles returns a walk over the files under dir that filter
+// selects, skipping ignored files and directories.
+func walkTextFiles(dir string, cache *Cache, filter *fileFilter) func(visit func(path string) error) error {
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, cache)
+
+	return func(visit func(path string) error) error {
+		return walkTree(dir, filter, func(path string, info os.FileInfo) error {
+			// Skip directories
+			if info.IsDir() {
+				// Check if directory should be ignored
+				if gi.Match(path, true) || filter.skipDir(path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
-	if err != nil {
-		return nil, err
-	}
+			// Check if file is ignored or filtered out
+			if gi.Match(path, false) || !filter.matchFile(path) {
+				return nil
+			}
 
-	return results, nil
+			return visit(path)
+		})
+	}
 }
 
-// searchFile searches for pattern matches in a file.
-func searchFile(path string, re *regexp.Regexp) ([]Result, error) {
+// searchFile searches for pattern matches in a file, collecting the
+// context lines requested in opts.
+func searchFile(path string, re *regexp.Regexp, opts Options) ([]Result, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -108,22 +223,41 @@ func searchFile(path string, re *regexp.Regexp) ([]Result, error) {
 	scanner := bufio.NewScanner(file)
 	lineNum := 1
 
+	// Recent non-matching lines, kept for before-context
+	var before []ContextLine
+	// Number of after-context lines still owed to the last match
+	afterRemaining := 0
+
 	for scanner.Scan() {
 		line := scanner.Text()
-		if re.MatchString(line) {
-			// Find column position
-			loc := re.FindStringIndex(line)
-			column := 0
-			if len(loc) > 0 {
-				column = loc[0]
+		if locs := re.FindAllStringIndex(line, matchLimit(opts)); locs != nil {
+			for i, loc := range locs {
+				result := Result{
+					Path:      path,
+					Line:      lineNum,
+					Column:    loc[0],
+					Match:     line,
+					EndLine:   lineNum,
+					EndColumn: loc[1],
+					Matched:   line[loc[0]:loc[1]],
+				}
+				// Context belongs to the line, so attach it to its first match
+				if i == 0 {
+					result.Before = before
+				}
+				results = append(results, result)
+			}
+			before = nil
+			afterRemaining = opts.AfterContext
+		} else if afterRemaining > 0 {
+			last := &results[len(results)-1]
+			last.After = append(last.After, ContextLine{Line: lineNum, Text: line})
+			afterRemaining--
+		} else if opts.BeforeContext > 0 {
+			before = append(before, ContextLine{Line: lineNum, Text: line})
+			if len(before) > opts.BeforeContext {
+				before = before[1:]
 			}
-
-			results = append(results, Result{
-				Path:   path,
-				Line:   lineNum,
-				Column: column,
-				Match:  line,
-			})
 		}
 		lineNum++
 	}
@@ -135,6 +269,90 @@ func searchFile(path string, re *regexp.Regexp) ([]Result, error) {
 	return results, nil
 }
 
+// searchFileMultiline searches the whole content of a file at once so that
+// matches may span lines. Each result's Match holds the text of the line the
+// match starts on.
+func searchFileMultiline(path string, re *regexp.Regexp, opts Options) ([]Result, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Byte offset at which each line starts
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' && i+1 < len(content) {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	totalLines := len(lineStarts)
+
+	// lineOf returns the 1-based line containing the byte offset
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	}
+	lineText := func(n int) string {
+		end := len(content)
+		if n < len(lineStarts) {
+			end = lineStarts[n]
+		}
+		return strings.TrimSuffix(string(content[lineStarts[n-1]:end]), "\n")
+	}
+
+	var results []Result
+	for _, loc := range re.FindAllIndex(content, -1) {
+		line := lineOf(loc[0])
+		if !opts.AllMatches && len(results) > 0 && results[len(results)-1].Line == line {
+			continue
+		}
+
+		// A match ending in a newline ends on the line the newline belongs to
+		endLine := line
+		if loc[1] > loc[0] {
+			endLine = lineOf(loc[1] - 1)
+		}
+
+		results = append(results, Result{
+			Path:      path,
+			Line:      line,
+			Column:    loc[0] - lineStarts[line-1],
+			Match:     lineText(line),
+			EndLine:   endLine,
+			EndColumn: loc[1] - lineStarts[endLine-1],
+			Matched:   string(content[loc[0]:loc[1]]),
+		})
+	}
+
+	// Attach context, giving each line to at most one result
+	lastPrinted := 0
+	for i := range results {
+		r := &results[i]
+		for n := max(lastPrinted+1, r.Line-opts.BeforeContext); n < r.Line; n++ {
+			r.Before = append(r.Before, ContextLine{Line: n, Text: lineText(n)})
+		}
+		lastPrinted = max(lastPrinted, r.EndLine)
+
+		limit := min(r.EndLine+opts.AfterContext, totalLines)
+		if i+1 < len(results) {
+			limit = min(limit, results[i+1].Line-1)
+		}
+		for n := lastPrinted + 1; n <= limit; n++ {
+			r.After = append(r.After, ContextLine{Line: n, Text: lineText(n)})
+			lastPrinted = n
+		}
+	}
+
+	return results, nil
+}
+
+// matchLimit returns the number of matches to report per line.
+func matchLimit(opts Options) int {
+	if opts.AllMatches {
+		return -1
+	}
+	return 1
+}
+
 // IsBinaryFile checks if a file is binary by looking for null bytes.
 func IsBinaryFile(path string) bool {
 	file, err := os.Open(path)
@@ -150,28 +368,100 @@ func IsBinaryFile(path string) bool {
 		return false
 	}
 
-	// Check for null bytes
-	return bytes.Contains(buf[:n], []byte{0})
+	return isBinaryContent(buf[:n])
+}
+
+// isBinaryContent reports whether content, or at least its first 512
+// bytes, looks binary because it contains a null byte.
+func isBinaryContent(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 512)], 0) >= 0
 }
 
 // FormatEmacsOutput formats results in Emacs compilation mode format.
 // Format: filename:line:column: matching_line
+//
+// Context lines use grep's filename-line-text form, and non-adjacent groups
+// of context are separated by a "--" line.
 func FormatEmacsOutput(results []Result) string {
-	var output strings.Builder
-
+	hasContext := false
 	for _, result := range results {
-		// Format: path:line:column: match
-		fmt.Fprintf(&output, "%s:%d:%d: %s\n",
-			result.Path,
-			result.Line,
-			result.Column,
-			result.Match,
-		)
+		if len(result.Before) > 0 || len(result.After) > 0 {
+			hasContext = true
+			break
+		}
 	}
 
+	var output strings.Builder
+	w := NewEmacsWriter(&output, hasContext)
+	for _, result := range results {
+		w.Write(result)
+	}
 	return output.String()
 }
 
+// EmacsWriter writes results one at a time in the format produced by
+// FormatEmacsOutput, for printing results as a streaming search finds them.
+type EmacsWriter struct {
+	w          io.Writer
+	hasContext bool
+
+	wrote    bool
+	lastPath string
+	lastLine int
+}
+
+// NewEmacsWriter returns an EmacsWriter writing to w. hasContext enables the
+// "--" separators between groups and should be set when context lines were
+// requested.
+func NewEmacsWriter(w io.Writer, hasContext bool) *EmacsWriter {
+	return &EmacsWriter{w: w, hasContext: hasContext}
+}
+
+// Write writes a result and its context lines.
+func (e *EmacsWriter) Write(result Result) error {
+	var output strings.Builder
+
+	if e.hasContext && e.wrote {
+		first := result.Line
+		if len(result.Before) > 0 {
+			first = result.Before[0].Line
+		}
+		if result.Path != e.lastPath || first > e.lastLine+1 {
+			output.WriteString("--\n")
+		}
+	}
+
+	for _, ctx := range result.Before {
+		fmt.Fprintf(&output, "%s-%d-%s\n", result.Path, ctx.Line, ctx.Text)
+	}
+
+	// Format: path:line:column: match, with symbols preceded by their kind
+	match := result.Match
+	if result.Kind != "" {
+		match = result.Kind + " " + match
+	}
+	fmt.Fprintf(&output, "%s:%d:%d: %s\n",
+		result.Path,
+		result.Line,
+		result.Column,
+		match,
+	)
+
+	for _, ctx := range result.After {
+		fmt.Fprintf(&output, "%s-%d-%s\n", result.Path, ctx.Line, ctx.Text)
+	}
+
+	e.wrote = true
+	e.lastPath = result.Path
+	e.lastLine = max(result.Line, result.EndLine)
+	if len(result.After) > 0 {
+		e.lastLine = result.After[len(result.After)-1].Line
+	}
+
+	_, err := io.WriteString(e.w, output.String())
+	return err
+}
+
 // Symbol-related functionality
 
 // IsSupportedSymbolFile checks if a file is a supported type for symbol search.
@@ -187,109 +477,192 @@ func IsSupportedSymbolFile(filename string) bool {
 
 // FindSymbols searches for symbols matching a pattern in code files.
 func FindSymbols(dir string, pattern string) ([]Result, error) {
+	return FindSymbolsWithOptions(dir, pattern, Options{})
+}
+
+// FindSymbolsWithOptions is like FindSymbols but parses files on a pool of
+// opts.Workers goroutines. Results come in walk order, with the entries of
+// each directory in lexical order, then by line, then by column.
+func FindSymbolsWithOptions(dir string, pattern string, opts Options) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return FindSymbolsStream(context.Background(), dir, pattern, opts, emit)
+	})
+}
+
+// FindSymbolsStream is like FindSymbolsWithOptions but passes each result to
+// fn as soon as it is available. It stops early under the same conditions
+// as FindStream.
+func FindSymbolsStream(ctx context.Context, dir string, pattern string, opts Options, fn func(Result) error) error {
 	// Compile regex pattern
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		return fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("directory does not exist: %s", dir)
+		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return err
 	}
 
-	var results []Result
-
-	// Walk the directory tree
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			if gi != nil {
-				relPath, _ := filepath.Rel(dir, path)
-				if relPath != "." && gi.MatchesPath(relPath) {
-					return filepath.SkipDir
-				}
-			}
-			return nil
-		}
-
-		// Check if file is supported for symbol search
-		if !IsSupportedSymbolFile(path) {
-			return nil
-		}
-
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-		if gi != nil && gi.MatchesPath(relPath) {
-			return nil
-		}
+	// Reuse the symbols of unchanged files when the tree was indexed
+	extract := extractSymbols
+	if index := opts.Cache.symbolIndex(dir); index != nil {
+		extract = index.symbols
+		// The index is only a cache, so failing to update it does not fail
+		// the search
+		defer index.save()
+	}
 
-		// Extract and search symbols
-		symbols, err := extractSymbols(path)
+	// Extract and search symbols in parallel as the walk finds files
+	return streamFiles(ctx, opts, walkSymbolFiles(dir, opts.Cache, filter), func(path string) ([]Result, error) {
+		symbols, err := extract(path)
 		if err != nil {
-			return nil // Skip files we can't parse
+			return nil, err // Skip files we can't parse
 		}
 
+		// Symbols match by their own name or their qualified name
+		var matches []Result
 		for _, symbol := range symbols {
-			if re.MatchString(symbol.Name) {
-				results = append(results, Result{
+			if !matchesKind(symbol.Kind, opts.Kinds) {
+				continue
+			}
+			qualified := symbol.QualifiedName()
+			if re.MatchString(symbol.Name) || re.MatchString(qualified) {
+				matches = append(matches, Result{
 					Path:   path,
 					Line:   symbol.Line,
 					Column: symbol.Column,
-					Match:  symbol.Name,
+					Match:  qualified,
+					Kind:   symbol.Kind,
 				})
 			}
 		}
+		return matches, nil
+	}, fn)
+}
 
-		return nil
+// ListSymbols returns every symbol in path, which may be a single code file
+// or a directory searched like FindSymbols. Only symbols of opts.Kinds are
+// listed when it is set.
+func ListSymbols(path string, opts Options) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return ListSymbolsStream(context.Background(), path, opts, emit)
 	})
+}
 
-	if err != nil {
-		return nil, err
-	}
+// ListSymbolsStream is like ListSymbols but passes each result to fn as
+// soon as it is available.
+func ListSymbolsStream(ctx context.Context, path string, opts Options, fn func(Result) error) error {
+	return FindSymbolsStream(ctx, path, "", opts, fn)
+}
 
-	return results, nil
+// kindAliases maps alternative spellings of symbol kinds accepted by
+// Options.Kinds to the kind they stand for.
+var kindAliases = map[string]string{
+	"func": "function",
+}
+
+// matchesKind reports whether a symbol of the given kind passes the kinds
+// filter. Kinds compare case-insensitively and through kindAliases, so that
+// "function" also selects Go's "func" symbols.
+func matchesKind(kind string, kinds []string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	normalize := func(k string) string {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if alias, ok := kindAliases[k]; ok {
+			return alias
+		}
+		return k
+	}
+	kind = normalize(kind)
+	for _, k := range kinds {
+		if normalize(k) == kind {
+			return true
+		}
+	}
+	return false
 }
 
 // Symbol represents a code symbol (function, class, variable, etc.)
 type Symbol struct {
-	Name   string
-	Line   int
-	Column int
-	Kind   string // "function", "class", "variable", etc.
+	Name       string
+	Line       int
+	Column     int
+	Kind       string   // "function", "class", "variable", etc.
+	Receiver   string   // enclosing type of a method or field, if any
+	Exported   bool     // visible outside its package or module
+	Decorators []string // decorators applied to the definition, if any
+}
+
+// QualifiedName returns the name of the symbol qualified by its receiver,
+// as in "Client.fetch".
+func (s Symbol) QualifiedName() string {
+	if s.Receiver == "" {
+		return s.Name
+	}
+	return s.Receiver + "." + s.Name
+}
+
+// walkSymbolFiles returns a walk over the code files under dir that support
+// symbol search and that filter selects, skipping ignored files and
+// directories.
+func walkSymbolFiles(dir string, cache *Cache, filter *fileFilter) func(visit func(path string) error) error {
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, cache)
+
+	return func(visit func(path string) error) error {
+		return walkTree(dir, filter, func(path string, info os.FileInfo) error {
+			// Skip directories
+			if info.IsDir() {
+				if gi.Match(path, true) || filter.skipDir(path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Check if file is supported for symbol search
+			if !IsSupportedSymbolFile(path) || !filter.matchFile(path) {
+				return nil
+			}
+
+			// Check if file is ignored
+			if gi.Match(path, false) {
+				return nil
+			}
+
+			return visit(path)
+		})
+	}
 }
 
 // extractSymbols extracts symbols from a file based on its language.
 func extractSymbols(path string) ([]Symbol, error) {
-	ext := filepath.Ext(path)
-
 	// Read file content
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return extractSymbolsFromContent(path, content)
+}
+
+// extractSymbolsFromContent extracts symbols from the content of the file
+// at path, choosing the language by the file's extension.
+func extractSymbolsFromContent(path string, content []byte) ([]Symbol, error) {
+	ext := filepath.Ext(path)
 
-	// Use simple regex-based extraction for now
-	// This is a fallback approach that works reasonably well
 	switch ext {
 	case ".go":
 		return extractGoSymbols(content)
 	case ".ts", ".tsx", ".js", ".jsx":
-		return extractJSSymbols(content)
+		// Only TypeScript proper uses <Type> assertions instead of JSX
+		return extractJSSymbols(content, ext != ".ts")
 	case ".py":
 		return extractPythonSymbols(content)
 	case ".sql":
@@ -299,190 +672,47 @@ func extractSymbols(path string) ([]Symbol, error) {
 	}
 }
 
-// extractGoSymbols extracts symbols from Go code using regex
-func extractGoSymbols(content []byte) ([]Symbol, error) {
-	var symbols []Symbol
-	lines := bytes.Split(content, []byte("\n"))
-
-	// Match function definitions: func FuncName( or func (receiver) FuncName(
-	funcRe := regexp.MustCompile(`^\s*func\s+(?:\([^)]+\)\s+)?(\w+)\s*\(`)
-	// Match type definitions: type TypeName struct/interface
-	typeRe := regexp.MustCompile(`^\s*type\s+(\w+)\s+(?:struct|interface)`)
-	// Match const/var declarations: const/var Name
-	varRe := regexp.MustCompile(`^\s*(?:const|var)\s+(\w+)`)
-
-	for i, line := range lines {
-		if matches := funcRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "function",
-			})
-		}
-		if matches := typeRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "type",
-			})
-		}
-		if matches := varRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "variable",
-			})
-		}
-	}
-
-	return symbols, nil
+// Replace searches for a pattern in all text files and replaces it with the replacement string.
+// It respects .gitignore rules and only modifies text files. The run is
+// recorded in a journal so that it can be reverted with Undo.
+func Replace(dir string, pattern string, replacement string) ([]Result, error) {
+	return ReplaceWithOptions(dir, pattern, replacement, Options{})
 }
 
-// extractJSSymbols extracts symbols from JavaScript/TypeScript code
-func extractJSSymbols(content []byte) ([]Symbol, error) {
-	var symbols []Symbol
-	lines := bytes.Split(content, []byte("\n"))
-
-	// Match function definitions
-	funcRe := regexp.MustCompile(`^\s*(?:export\s+)?(?:async\s+)?function\s+(\w+)\s*\(`)
-	// Match class definitions
-	classRe := regexp.MustCompile(`^\s*(?:export\s+)?class\s+(\w+)`)
-	// Match const/let/var declarations
-	varRe := regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(\w+)`)
-	// Match method definitions
-	methodRe := regexp.MustCompile(`^\s*(\w+)\s*\([^)]*\)\s*{`)
-
-	for i, line := range lines {
-		if matches := funcRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "function",
-			})
-		}
-		if matches := classRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "class",
-			})
-		}
-		if matches := varRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "variable",
-			})
-		}
-		if matches := methodRe.FindSubmatch(line); matches != nil {
-			// Skip if it looks like a function keyword
-			if !bytes.Contains(line, []byte("function")) {
-				symbols = append(symbols, Symbol{
-					Name:   string(matches[1]),
-					Line:   i + 1,
-					Column: bytes.Index(line, matches[1]),
-					Kind:   "method",
-				})
-			}
-		}
+// ReplaceWithOptions is like Replace but honors opts. With opts.AllMatches
+// it reports one result per replaced occurrence rather than one per line.
+func ReplaceWithOptions(dir string, pattern string, replacement string, opts Options) ([]Result, error) {
+	changes, err := PlanReplace(dir, pattern, replacement, opts)
+	if err != nil {
+		return nil, err
 	}
-
-	return symbols, nil
-}
-
-// extractPythonSymbols extracts symbols from Python code
-func extractPythonSymbols(content []byte) ([]Symbol, error) {
-	var symbols []Symbol
-	lines := bytes.Split(content, []byte("\n"))
-
-	// Match function/method definitions: def func_name(
-	funcRe := regexp.MustCompile(`^\s*def\s+(\w+)\s*\(`)
-	// Match class definitions: class ClassName
-	classRe := regexp.MustCompile(`^\s*class\s+(\w+)`)
-	// Match variable assignments at module level (simple heuristic)
-	varRe := regexp.MustCompile(`^(\w+)\s*=`)
-
-	for i, line := range lines {
-		if matches := funcRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "function",
-			})
-		}
-		if matches := classRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "class",
-			})
-		}
-		if matches := varRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: 0,
-				Kind:   "variable",
-			})
-		}
+	description := fmt.Sprintf("replace %q with %q", pattern, replacement)
+	if _, err := ApplyChangesWithJournal(dir, description, changes); err != nil {
+		return nil, err
 	}
-
-	return symbols, nil
+	return changeResults(changes), nil
 }
 
-// extractSQLSymbols extracts symbols from SQL code
-func extractSQLSymbols(content []byte) ([]Symbol, error) {
-	var symbols []Symbol
-	lines := bytes.Split(content, []byte("\n"))
-
-	// Match CREATE TABLE
-	tableRe := regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\s+(\w+)`)
-	// Match CREATE FUNCTION/PROCEDURE
-	funcRe := regexp.MustCompile(`(?i)^\s*CREATE\s+(?:FUNCTION|PROCEDURE)\s+(\w+)`)
-	// Match CREATE VIEW
-	viewRe := regexp.MustCompile(`(?i)^\s*CREATE\s+VIEW\s+(\w+)`)
+// Change is the pending rewrite of a single file by a replace operation.
+type Change struct {
+	Path    string
+	Old     []byte
+	New     []byte
+	Results []Result
+}
 
-	for i, line := range lines {
-		if matches := tableRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "table",
-			})
-		}
-		if matches := funcRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "function",
-			})
-		}
-		if matches := viewRe.FindSubmatch(line); matches != nil {
-			symbols = append(symbols, Symbol{
-				Name:   string(matches[1]),
-				Line:   i + 1,
-				Column: bytes.Index(line, matches[1]),
-				Kind:   "view",
-			})
-		}
+// changeResults flattens the results of a set of changes.
+func changeResults(changes []Change) []Result {
+	var results []Result
+	for _, change := range changes {
+		results = append(results, change.Results...)
 	}
-
-	return symbols, nil
+	return results
 }
 
-// Replace searches for a pattern in all text files and replaces it with the replacement string.
-// It respects .gitignore rules and only modifies text files.
-func Replace(dir string, pattern string, replacement string) ([]Result, error) {
+// PlanReplace computes the changes Replace would make without writing
+// anything, so that they can be previewed or applied with ApplyChanges.
+func PlanReplace(dir string, pattern string, replacement string, opts Options) ([]Change, error) {
 	// Compile regex pattern
 	re, err := regexp.Compile(pattern)
 	if err != nil {
@@ -494,38 +724,28 @@ func Replace(dir string, pattern string, replacement string) ([]Result, error) {
 		return nil, fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	var results []Result
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, opts.Cache)
 
-	// Walk the directory tree
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
+	var changes []Change
 
+	// Walk the directory tree
+	err = walkTree(dir, filter, func(path string, info os.FileInfo) error {
 		// Skip directories
 		if info.IsDir() {
-			if gi != nil {
-				relPath, _ := filepath.Rel(dir, path)
-				if relPath != "." && gi.MatchesPath(relPath) {
-					return filepath.SkipDir
-				}
+			if gi.Match(path, true) || filter.skipDir(path) {
+				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-		if gi != nil && gi.MatchesPath(relPath) {
+		// Check if file is ignored or filtered out
+		if gi.Match(path, false) || !filter.matchFile(path) {
 			return nil
 		}
 
@@ -535,12 +755,15 @@ func Replace(dir string, pattern string, replacement string) ([]Result, error) {
 		}
 
 		// Replace in file
-		matches, err := replaceInFile(path, re, replacement)
+		change, err := replaceInFile(path, re, replacement, opts)
 		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if change == nil {
 			return nil
 		}
 
-		results = append(results, matches...)
+		changes = append(changes, *change)
 		return nil
 	})
 
@@ -548,11 +771,13 @@ func Replace(dir string, pattern string, replacement string) ([]Result, error) {
 		return nil, err
 	}
 
-	return results, nil
+	return changes, nil
 }
 
-// replaceInFile performs replacements in a file and writes the changes back.
-func replaceInFile(path string, re *regexp.Regexp, replacement string) ([]Result, error) {
+// replaceInFile computes the replacements in a file. It returns nil if the
+// file does not match. Each result carries the rewritten line in Match and
+// the original text and position of the replaced occurrence.
+func replaceInFile(path string, re *regexp.Regexp, replacement string, opts Options) (*Change, error) {
 	// Read file content
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -564,71 +789,99 @@ func replaceInFile(path string, re *regexp.Regexp, replacement string) ([]Result
 	modified := false
 
 	for i, line := range lines {
-		if re.Match(line) {
-			loc := re.FindIndex(line)
-			column := 0
-			if len(loc) > 0 {
-				column = loc[0]
-			}
+		locs := re.FindAllIndex(line, -1)
+		if locs == nil {
+			continue
+		}
 
-			// Perform replacement
-			newLine := re.ReplaceAll(line, []byte(replacement))
-			lines[i] = newLine
-			modified = true
+		// Perform replacement
+		newLine := re.ReplaceAll(line, []byte(replacement))
+		lines[i] = newLine
+		modified = true
 
+		if !opts.AllMatches {
+			locs = locs[:1]
+		}
+		for _, loc := range locs {
 			results = append(results, Result{
-				Path:   path,
-				Line:   i + 1,
-				Column: column,
-				Match:  string(newLine),
+				Path:      path,
+				Line:      i + 1,
+				Column:    loc[0],
+				Match:     string(newLine),
+				EndLine:   i + 1,
+				EndColumn: loc[1],
+				Matched:   string(line[loc[0]:loc[1]]),
 			})
 		}
 	}
 
-	// Write back if modified
-	if modified {
-		newContent := bytes.Join(lines, []byte("\n"))
-		if err := os.WriteFile(path, newContent, 0644); err != nil {
-			return nil, err
-		}
+	if !modified {
+		return nil, nil
 	}
 
-	return results, nil
+	return &Change{
+		Path:    path,
+		Old:     content,
+		New:     bytes.Join(lines, []byte("\n")),
+		Results: results,
+	}, nil
 }
 
 // ReplaceSymbol performs semantic renaming of symbols across code files.
 // It finds all references to a symbol and renames them to the new name.
+// In Go files the rename is type-checked: only the package-level
+// declaration, method or field named oldName that is declared under dir is
+// renamed, together with its references anywhere in the enclosing module.
+// oldName may be qualified by the package, the type declaring it or both,
+// as in lib.Store.Len, or be the file:line:column position of an
+// identifier, and is an error when it matches several declarations. Other
+// languages rename every whole-word occurrence of the name. Like Replace,
+// the run is journaled.
 func ReplaceSymbol(dir string, oldName string, newName string) ([]Result, error) {
+	changes, err := PlanReplaceSymbol(dir, oldName, newName, Options{})
+	if err != nil {
+		return nil, err
+	}
+	description := fmt.Sprintf("rename %s to %s", oldName, newName)
+	if _, err := ApplyChangesWithJournal(dir, description, changes); err != nil {
+		return nil, err
+	}
+	return changeResults(changes), nil
+}
+
+// PlanReplaceSymbol computes the changes ReplaceSymbol would make without
+// writing anything. The filtering fields and walk controls of opts choose
+// the files whose Go declarations are renamed, but never drop a reference
+// to them: a reference under dir in a file the filters exclude is an error
+// rather than a rename that would not compile.
+func PlanReplaceSymbol(dir string, oldName string, newName string, opts Options) ([]Change, error) {
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return nil, fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	var results []Result
+	spec, err := parseSymbolSpec(oldName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Walk the directory tree
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, opts.Cache)
+
+	var changes []Change
+	goFiles := make(map[string]bool) // absolute paths of the Go files found
 
+	// Walk the directory tree
+	err = walkTree(dir, filter, func(path string, info os.FileInfo) error {
 		// Skip directories
 		if info.IsDir() {
-			if gi != nil {
-				relPath, _ := filepath.Rel(dir, path)
-				if relPath != "." && gi.MatchesPath(relPath) {
-					return filepath.SkipDir
-				}
+			if gi.Match(path, true) || filter.skipDir(path) {
+				return filepath.SkipDir
 			}
 			return nil
 		}
@@ -638,19 +891,29 @@ func ReplaceSymbol(dir string, oldName string, newName string) ([]Result, error)
 			return nil
 		}
 
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-		if gi != nil && gi.MatchesPath(relPath) {
+		// Check if file is ignored or filtered out
+		if gi.Match(path, false) || !filter.matchFile(path) {
+			return nil
+		}
+
+		// Go files are renamed separately with type information
+		if filepath.Ext(path) == ".go" {
+			if absPath, err := filepath.Abs(path); err == nil {
+				goFiles[absPath] = true
+			}
 			return nil
 		}
 
 		// Replace symbols in file
-		matches, err := replaceSymbolInFile(path, oldName, newName)
+		change, err := replaceSymbolInFile(path, spec.name, newName)
 		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if change == nil {
 			return nil
 		}
 
-		results = append(results, matches...)
+		changes = append(changes, *change)
 		return nil
 	})
 
@@ -658,11 +921,24 @@ func ReplaceSymbol(dir string, oldName string, newName string) ([]Result, error)
 		return nil, err
 	}
 
-	return results, nil
+	goChanges, err := planGoRename(dir, oldName, newName, goFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range goChanges {
+		if rel, err := filepath.Rel(dir, change.Path); err == nil && !isOutsideRel(rel) && !filter.matchFile(change.Path) {
+			return nil, fmt.Errorf("cannot rename %s: %s refers to it but is excluded by the path filters", oldName, change.Path)
+		}
+		changes = append(changes, change)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes, nil
 }
 
-// replaceSymbolInFile performs semantic symbol replacement in a file.
-func replaceSymbolInFile(path string, oldName string, newName string) ([]Result, error) {
+// replaceSymbolInFile computes semantic symbol replacement in a file.
+// It returns nil if the symbol does not occur in the file.
+func replaceSymbolInFile(path string, oldName string, newName string) (*Change, error) {
 	// Read file content
 	content, err := os.ReadFile(path)
 	if err != nil {
@@ -701,152 +977,143 @@ func replaceSymbolInFile(path string, oldName string, newName string) ([]Result,
 		}
 	}
 
-	// Write back if modified
-	if modified {
-		newContent := bytes.Join(lines, []byte("\n"))
-		if err := os.WriteFile(path, newContent, 0644); err != nil {
-			return nil, err
-		}
+	if !modified {
+		return nil, nil
 	}
 
-	return results, nil
+	return &Change{
+		Path:    path,
+		Old:     content,
+		New:     bytes.Join(lines, []byte("\n")),
+		Results: results,
+	}, nil
 }
 
 // GlobFiles recursively lists all files matching the given regex pattern.
 // It respects .gitignore rules and searches from the specified directory.
 func GlobFiles(dir string, pattern string) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return GlobFilesStream(context.Background(), dir, pattern, Options{}, emit)
+	})
+}
+
+// GlobFilesStream is like GlobFiles but passes each file to fn as the walk
+// finds it. It stops early under the same conditions as FindStream.
+func GlobFilesStream(ctx context.Context, dir string, pattern string, opts Options, fn func(Result) error) error {
 	// Compile regex pattern
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		return fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("directory does not exist: %s", dir)
+		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return err
 	}
 
-	var results []Result
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, opts.Cache)
 
 	// Walk the directory tree
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			if gi != nil {
-				relPath, _ := filepath.Rel(dir, path)
-				if relPath != "." && gi.MatchesPath(relPath) {
+	walk := func(visit func(path string) error) error {
+		return walkTree(dir, filter, func(path string, info os.FileInfo) error {
+			// Skip directories
+			if info.IsDir() {
+				if gi.Match(path, true) || filter.skipDir(path) {
 					return filepath.SkipDir
 				}
+				return nil
 			}
-			return nil
-		}
 
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-		if gi != nil && gi.MatchesPath(relPath) {
-			return nil
-		}
-
-		// Check if filename matches pattern
-		filename := filepath.Base(path)
-		if re.MatchString(filename) {
-			results = append(results, Result{
-				Path:   path,
-				Line:   0,
-				Column: 0,
-				Match:  filename,
-			})
-		}
+			// Check if file is ignored or filtered out
+			if gi.Match(path, false) || !filter.matchFile(path) {
+				return nil
+			}
 
-		return nil
-	})
+			// Check if filename matches pattern
+			if !re.MatchString(filepath.Base(path)) {
+				return nil
+			}
 
-	if err != nil {
-		return nil, err
+			return visit(path)
+		})
 	}
 
-	return results, nil
+	return walkStream(ctx, opts, walk, func(path string) Result {
+		return Result{
+			Path:   path,
+			Line:   0,
+			Column: 0,
+			Match:  filepath.Base(path),
+		}
+	}, fn)
 }
 
 // GlobDirectories recursively lists all directories matching the given regex pattern.
 // It respects .gitignore rules and searches from the specified directory.
 func GlobDirectories(dir string, pattern string) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return GlobDirectoriesStream(context.Background(), dir, pattern, Options{}, emit)
+	})
+}
+
+// GlobDirectoriesStream is like GlobDirectories but passes each directory to
+// fn as the walk finds it. It stops early under the same conditions as
+// FindStream.
+func GlobDirectoriesStream(ctx context.Context, dir string, pattern string, opts Options, fn func(Result) error) error {
 	// Compile regex pattern
 	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		return fmt.Errorf("invalid regex pattern: %w", err)
 	}
 
 	// Check if directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("directory does not exist: %s", dir)
+		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
-	// Load .gitignore if it exists
-	var gi *ignore.GitIgnore
-	gitignorePath := filepath.Join(dir, ".gitignore")
-	if _, err := os.Stat(gitignorePath); err == nil {
-		gi, err = ignore.CompileIgnoreFile(gitignorePath)
-		if err != nil {
-			gi = nil
-		}
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return err
 	}
 
-	var results []Result
+	// Load the .gitignore, exclude and global ignore rules for this tree
+	gi := newIgnoreMatcher(dir, opts.Cache)
 
 	// Walk the directory tree
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Only process directories
-		if !info.IsDir() {
-			return nil
-		}
-
-		// Skip the root directory itself
-		if path == dir {
-			return nil
-		}
-
-		// Get relative path for gitignore matching
-		relPath, _ := filepath.Rel(dir, path)
-		if gi != nil && gi.MatchesPath(relPath) {
-			return filepath.SkipDir
-		}
+	walk := func(visit func(path string) error) error {
+		return walkTree(dir, filter, func(path string, info os.FileInfo) error {
+			// Only process directories
+			if !info.IsDir() {
+				return nil
+			}
 
-		// Check if directory name matches pattern
-		dirname := filepath.Base(path)
-		if re.MatchString(dirname) {
-			results = append(results, Result{
-				Path:   path,
-				Line:   0,
-				Column: 0,
-				Match:  dirname,
-			})
-		}
+			// Check if directory is ignored or excluded
+			if gi.Match(path, true) || filter.skipDir(path) {
+				return filepath.SkipDir
+			}
 
-		return nil
-	})
+			// Check if directory name matches pattern. Directories outside
+			// the include globs are still walked for matches below them.
+			if !re.MatchString(filepath.Base(path)) || !filter.included(path, true) {
+				return nil
+			}
 
-	if err != nil {
-		return nil, err
+			return visit(path)
+		})
 	}
 
-	return results, nil
+	return walkStream(ctx, opts, walk, func(path string) Result {
+		return Result{
+			Path:   path,
+			Line:   0,
+			Column: 0,
+			Match:  filepath.Base(path),
+		}
+	}, fn)
 }
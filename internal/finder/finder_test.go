@@ -1,6 +1,7 @@
 package finder
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -43,16 +44,16 @@ func TestFind(t *testing.T) {
 		{
 			name:            "simple word search",
 			pattern:         "hello",
-			expectedFiles:   []string{"file1.txt", "file3.txt", "file4.go", "file.txt", "test.md"},
-			expectedCount:   5,
-			unexpectedFiles: []string{"secret.txt", "binary.bin"},
+			expectedFiles:   []string{"file1.txt", "file3.txt", "file4.go", "test.md"},
+			expectedCount:   4,
+			unexpectedFiles: []string{"secret.txt", "binary.bin", ".hidden"},
 		},
 		{
 			name:            "regex pattern",
 			pattern:         "w[oO]rld",
-			expectedFiles:   []string{"file1.txt", "file3.txt", "file4.go", "file.txt", "test.md"},
-			expectedCount:   5,
-			unexpectedFiles: []string{"secret.txt"},
+			expectedFiles:   []string{"file1.txt", "file3.txt", "file4.go", "test.md"},
+			expectedCount:   4,
+			unexpectedFiles: []string{"secret.txt", ".hidden"},
 		},
 		{
 			name:            "no matches",
@@ -108,6 +109,46 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestFindWithOptions_DeterministicOrder(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create enough files that several workers are busy at once
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join(tempDir, fmt.Sprintf("dir%02d", i%7))
+		os.MkdirAll(dir, 0755)
+		content := "needle one\nhay\nneedle two\nneedle three"
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%02d.txt", i)), []byte(content), 0644)
+	}
+
+	serial, err := FindWithOptions(tempDir, "needle", Options{Workers: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(serial) != 150 {
+		t.Fatalf("expected 150 results, got %d", len(serial))
+	}
+
+	// Results must be in walk order, which for these names is by path, then line
+	for i := 1; i < len(serial); i++ {
+		prev, cur := serial[i-1], serial[i]
+		if prev.Path > cur.Path || (prev.Path == cur.Path && prev.Line >= cur.Line) {
+			t.Fatalf("results out of order at %d: %s:%d before %s:%d",
+				i, prev.Path, prev.Line, cur.Path, cur.Line)
+		}
+	}
+
+	// Parallel runs must produce exactly the same output
+	for _, workers := range []int{0, 4, 16} {
+		parallel, err := FindWithOptions(tempDir, "needle", Options{Workers: workers})
+		if err != nil {
+			t.Fatalf("unexpected error with %d workers: %v", workers, err)
+		}
+		if FormatEmacsOutput(parallel) != FormatEmacsOutput(serial) {
+			t.Errorf("output with %d workers differs from serial output", workers)
+		}
+	}
+}
+
 func TestFind_InvalidPattern(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -153,6 +194,159 @@ func TestFormatEmacsOutput(t *testing.T) {
 	}
 }
 
+func TestFindWithOptions_Context(t *testing.T) {
+	tempDir := t.TempDir()
+
+	content := "one\ntwo\nmatch a\nfour\nmatch b\nsix\nseven\neight\nnine\nmatch c\neleven"
+	path := filepath.Join(tempDir, "file.txt")
+	os.WriteFile(path, []byte(content), 0644)
+
+	results, err := FindWithOptions(tempDir, "match", Options{BeforeContext: 2, AfterContext: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	// Overlapping windows are merged: "four" belongs to the first match only
+	contextLines := func(lines []ContextLine) []int {
+		var nums []int
+		for _, l := range lines {
+			nums = append(nums, l.Line)
+		}
+		return nums
+	}
+	expected := []struct {
+		before []int
+		after  []int
+	}{
+		{before: []int{1, 2}, after: []int{4}},
+		{before: nil, after: []int{6}},
+		{before: []int{8, 9}, after: []int{11}},
+	}
+	for i, exp := range expected {
+		if fmt.Sprint(contextLines(results[i].Before)) != fmt.Sprint(exp.before) {
+			t.Errorf("result %d: expected before context %v, got %v", i, exp.before, contextLines(results[i].Before))
+		}
+		if fmt.Sprint(contextLines(results[i].After)) != fmt.Sprint(exp.after) {
+			t.Errorf("result %d: expected after context %v, got %v", i, exp.after, contextLines(results[i].After))
+		}
+	}
+
+	want := path + "-1-one\n" +
+		path + "-2-two\n" +
+		path + ":3:0: match a\n" +
+		path + "-4-four\n" +
+		path + ":5:0: match b\n" +
+		path + "-6-six\n" +
+		"--\n" +
+		path + "-8-eight\n" +
+		path + "-9-nine\n" +
+		path + ":10:0: match c\n" +
+		path + "-11-eleven\n"
+	if output := FormatEmacsOutput(results); output != want {
+		t.Errorf("output mismatch:\nexpected:\n%s\ngot:\n%s", want, output)
+	}
+}
+
+func TestFindWithOptions_AllMatches(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "file.txt"), []byte("foo bar foo baz fooo\nnothing"), 0644)
+
+	// By default only the first match on a line is reported
+	results, err := FindWithOptions(tempDir, "fo+", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Column != 0 || results[0].EndColumn != 3 || results[0].Matched != "foo" {
+		t.Errorf("unexpected first match: %+v", results[0])
+	}
+
+	results, err = FindWithOptions(tempDir, "fo+", Options{AllMatches: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		column    int
+		endColumn int
+		matched   string
+	}{
+		{0, 3, "foo"},
+		{8, 11, "foo"},
+		{16, 20, "fooo"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, exp := range expected {
+		r := results[i]
+		if r.Line != 1 || r.Column != exp.column || r.EndColumn != exp.endColumn || r.Matched != exp.matched {
+			t.Errorf("result %d: expected %d-%d %q, got %d-%d %q",
+				i, exp.column, exp.endColumn, exp.matched, r.Column, r.EndColumn, r.Matched)
+		}
+		if r.Match != "foo bar foo baz fooo" {
+			t.Errorf("result %d: expected full line in Match, got %q", i, r.Match)
+		}
+	}
+}
+
+func TestFindWithOptions_Multiline(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "query.sql")
+	content := "-- users\nSELECT id,\n  name\nFROM users\nWHERE id = 1;\n\nSELECT 1 FROM dual;\n"
+	os.WriteFile(path, []byte(content), 0644)
+
+	// Without -U a pattern spanning lines never matches
+	results, err := FindWithOptions(tempDir, `SELECT[^;]*\nFROM`, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results in line mode, got %d", len(results))
+	}
+
+	results, err = FindWithOptions(tempDir, `SELECT[^;]*\nFROM \w+`, Options{Multiline: true, BeforeContext: 1, AfterContext: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	r := results[0]
+	if r.Line != 2 || r.Column != 0 || r.EndLine != 4 || r.EndColumn != 10 {
+		t.Errorf("expected span 2:0-4:10, got %d:%d-%d:%d", r.Line, r.Column, r.EndLine, r.EndColumn)
+	}
+	if r.Matched != "SELECT id,\n  name\nFROM users" {
+		t.Errorf("unexpected matched text: %q", r.Matched)
+	}
+	if len(r.Before) != 1 || r.Before[0].Line != 1 || len(r.After) != 1 || r.After[0].Line != 5 {
+		t.Errorf("unexpected context: before %+v, after %+v", r.Before, r.After)
+	}
+
+	// Emacs output points at the first line of the match
+	want := path + "-1--- users\n" +
+		path + ":2:0: SELECT id,\n" +
+		path + "-5-WHERE id = 1;\n"
+	if output := FormatEmacsOutput(results); output != want {
+		t.Errorf("output mismatch:\nexpected:\n%s\ngot:\n%s", want, output)
+	}
+
+	// ^ and $ match at line boundaries
+	results, err = FindWithOptions(tempDir, `;$\n^$\n^SELECT`, Options{Multiline: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Line != 5 || results[0].EndLine != 7 {
+		t.Errorf("expected one match from line 5 to 7, got %+v", results)
+	}
+}
+
 func TestIsBinaryFile(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -436,6 +630,66 @@ func TestReplace(t *testing.T) {
 	}
 }
 
+func TestReplaceWithOptions_AllMatches(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "file.txt")
+	os.WriteFile(path, []byte("cat and cat\ndog\ncat"), 0644)
+
+	results, err := ReplaceWithOptions(tempDir, "cat", "lion", Options{AllMatches: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 replaced occurrences, got %d", len(results))
+	}
+	if results[1].Line != 1 || results[1].Column != 8 || results[1].Matched != "cat" {
+		t.Errorf("unexpected second occurrence: %+v", results[1])
+	}
+	if results[1].Match != "lion and lion" {
+		t.Errorf("expected rewritten line in Match, got %q", results[1].Match)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "lion and lion\ndog\nlion" {
+		t.Errorf("unexpected file content: %q", content)
+	}
+}
+
+func TestPlanReplace(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "file.txt")
+	os.WriteFile(path, []byte("alpha\nbeta\nalpha beta\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "other.txt"), []byte("nothing"), 0644)
+
+	changes, err := PlanReplace(tempDir, "alpha", "gamma", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 changed file, got %d", len(changes))
+	}
+	if string(changes[0].New) != "gamma\nbeta\ngamma beta\n" {
+		t.Errorf("unexpected new content: %q", changes[0].New)
+	}
+	if len(changes[0].Results) != 2 {
+		t.Errorf("expected 2 results, got %d", len(changes[0].Results))
+	}
+
+	// Planning leaves the file untouched
+	content, _ := os.ReadFile(path)
+	if string(content) != "alpha\nbeta\nalpha beta\n" {
+		t.Fatalf("expected file to be unchanged by planning, got %q", content)
+	}
+
+	if err := ApplyChanges(changes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ = os.ReadFile(path)
+	if string(content) != "gamma\nbeta\ngamma beta\n" {
+		t.Errorf("expected changes to be applied, got %q", content)
+	}
+}
+
 func TestReplaceSymbol(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -669,3 +923,87 @@ func TestGlobDirectories_InvalidPattern(t *testing.T) {
 		t.Error("expected error for invalid regex pattern")
 	}
 }
+
+func TestFindSymbols_QualifiedName(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "client.py"), []byte("class Client:\n    def fetch(self):\n        pass\n\ndef fetch():\n    pass\n"), 0644)
+
+	results, err := FindSymbols(tempDir, `^Client\.fetch$`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Match != "Client.fetch" || results[0].Line != 2 {
+		t.Fatalf("expected only the method, got %+v", results)
+	}
+
+	// The bare name still matches both definitions
+	results, err = FindSymbols(tempDir, `^fetch$`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results, got %+v", results)
+	}
+}
+
+func TestFindSymbols_Kinds(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\ntype Server struct{}\n\nfunc (s *Server) Serve() {}\n\nfunc NewServer() *Server { return nil }\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "app.py"), []byte("class Server:\n    pass\n\ndef serve():\n    pass\n"), 0644)
+
+	tests := []struct {
+		name  string
+		kinds []string
+		want  []string
+	}{
+		{"every kind", nil, []string{"Server", "serve", "Server", "Server.Serve", "NewServer"}},
+		{"functions include go funcs", []string{"function"}, []string{"serve", "NewServer"}},
+		{"several kinds", []string{"class", "struct"}, []string{"Server", "Server"}},
+		{"case insensitive", []string{"METHOD"}, []string{"Server.Serve"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := FindSymbolsWithOptions(tempDir, `(?i)serve`, Options{Kinds: tt.kinds})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, result.Match)
+				if result.Kind == "" {
+					t.Errorf("result %s has no kind", result.Match)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListSymbols(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	os.WriteFile(path, []byte("package main\n\nconst Version = \"1\"\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "other.go"), []byte("package main\n\nvar debug bool\n"), 0644)
+
+	// A single file lists only its own symbols
+	results, err := ListSymbols(path, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	output := FormatEmacsOutput(results)
+	want := path + ":3:6: const Version\n" + path + ":5:5: func main\n"
+	if output != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, output)
+	}
+
+	results, err = ListSymbols(tempDir, Options{Kinds: []string{"var"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Match != "debug" {
+		t.Errorf("expected only the variable, got %+v", results)
+	}
+}
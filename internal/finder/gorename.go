@@ -0,0 +1,905 @@
+package finder
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goPackage is a package of the Go module being renamed in. Test files are
+// kept apart so that importers see the package without them.
+type goPackage struct {
+	path   string      // import path
+	files  []*ast.File // non-test files
+	tests  []*ast.File // _test.go files of the same package
+	xtests []*ast.File // _test.go files of the external test package
+}
+
+// goCheck is the outcome of type-checking one set of files.
+type goCheck struct {
+	pkg   *types.Package
+	info  *types.Info
+	files []*ast.File
+}
+
+// goModule loads the packages of a Go module and type-checks them from
+// source. It is the types.Importer for its own packages, so that they see
+// each other's declarations as the very same objects; every other import is
+// left to the standard library's source importer.
+type goModule struct {
+	fset    *token.FileSet
+	pkgs    map[string]*goPackage
+	paths   []string          // import paths in walk order
+	content map[string][]byte // source by file name
+	checked map[string]*goCheck
+	std     types.Importer
+}
+
+// findGoModule walks up from dir looking for a go.mod file. It returns the
+// module root and path, or dir and a GOPATH-style "_/dir" path outside a
+// module.
+func findGoModule(dir string) (root string, modPath string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if content, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) >= 2 && fields[0] == "module" {
+					return d, strings.Trim(fields[1], `"`)
+				}
+			}
+			return d, "_" + filepath.ToSlash(d)
+		}
+		if d == filepath.Dir(d) {
+			return dir, "_" + filepath.ToSlash(dir)
+		}
+	}
+}
+
+// loadGoModule parses the Go files of the module rooted at root that match
+// the current build context. Like the go command it skips testdata, vendor
+// and nested modules, and directories starting with "." or "_"; ignored
+// files are skipped too.
+func loadGoModule(root string, modPath string) (*goModule, error) {
+	m := &goModule{
+		fset:    token.NewFileSet(),
+		pkgs:    make(map[string]*goPackage),
+		content: make(map[string][]byte),
+		checked: make(map[string]*goCheck),
+	}
+	m.std = importer.ForCompiler(m.fset, "source", nil)
+
+	gi := newIgnoreMatcher(root, nil)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			name := info.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			if gi.Match(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".go" || gi.Match(path, false) {
+			return nil
+		}
+		if match, err := build.Default.MatchFile(filepath.Dir(path), info.Name()); err != nil || !match {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		// Files with syntax errors still take part with what could be parsed
+		file, _ := parser.ParseFile(m.fset, path, content, parser.SkipObjectResolution)
+		if file == nil {
+			return nil
+		}
+		m.content[path] = content
+
+		importPath := modPath
+		if rel, _ := filepath.Rel(root, filepath.Dir(path)); rel != "." {
+			importPath += "/" + filepath.ToSlash(rel)
+		}
+		pkg := m.pkgs[importPath]
+		if pkg == nil {
+			pkg = &goPackage{path: importPath}
+			m.pkgs[importPath] = pkg
+			m.paths = append(m.paths, importPath)
+		}
+
+		switch {
+		case !strings.HasSuffix(path, "_test.go"):
+			pkg.files = append(pkg.files, file)
+		case strings.HasSuffix(file.Name.Name, "_test"):
+			pkg.xtests = append(pkg.xtests, file)
+		default:
+			pkg.tests = append(pkg.tests, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Import implements types.Importer.
+func (m *goModule) Import(path string) (*types.Package, error) {
+	if _, ok := m.pkgs[path]; !ok {
+		return m.std.Import(path)
+	}
+	c, err := m.checkPackage(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.pkg, nil
+}
+
+// checkPackage type-checks the non-test files of a module package once.
+func (m *goModule) checkPackage(path string) (*goCheck, error) {
+	if c, ok := m.checked[path]; ok {
+		if c == nil {
+			return nil, fmt.Errorf("import cycle through %s", path)
+		}
+		return c, nil
+	}
+	m.checked[path] = nil
+	c := m.check(path, m.pkgs[path].files)
+	m.checked[path] = c
+	return c, nil
+}
+
+// check type-checks files as the package path. Type errors do not stop the
+// check, so that a module that does not fully compile can still be renamed
+// in.
+func (m *goModule) check(path string, files []*ast.File) *goCheck {
+	info := &types.Info{
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+	}
+	conf := types.Config{Importer: m, Error: func(error) {}}
+	pkg, _ := conf.Check(path, m.fset, files, info)
+	return &goCheck{pkg: pkg, info: info, files: files}
+}
+
+// checkAll type-checks every package of the module, including its in-package
+// and external tests.
+func (m *goModule) checkAll() []*goCheck {
+	var checks []*goCheck
+	for _, path := range m.paths {
+		pkg := m.pkgs[path]
+		if len(pkg.files) > 0 {
+			c, _ := m.checkPackage(path)
+			checks = append(checks, c)
+		}
+		if len(pkg.tests) > 0 {
+			files := append(append([]*ast.File(nil), pkg.files...), pkg.tests...)
+			checks = append(checks, m.check(path, files))
+		}
+		if len(pkg.xtests) > 0 {
+			checks = append(checks, m.check(path+"_test", pkg.xtests))
+		}
+	}
+	return checks
+}
+
+// goRenamer renames the Go declarations named oldName found in a directory,
+// along with every reference to them in the module.
+type goRenamer struct {
+	m       *goModule
+	oldName string
+	newName string
+	// targets holds the objects being renamed by declaration position, which
+	// identifies them across the separate checks of a package and its tests
+	targets map[token.Pos]types.Object
+}
+
+// symbolSpec names the symbol a rename applies to. It is given as a name,
+// optionally qualified by the Go package, the type declaring it or both, as
+// in lib.Count, Store.Len or lib.Store.Len, or as the file:line:column
+// position of an identifier declaring or referring to it.
+type symbolSpec struct {
+	name      string
+	qualifier string // package, type or package.type; empty if unqualified
+	path      string // absolute path of the position, if given as one
+	line      int
+	column    int
+}
+
+// parseSymbolSpec parses the symbol given to a rename.
+func parseSymbolSpec(spec string) (symbolSpec, error) {
+	if path, line, column, ok := ParsePosition(spec); ok {
+		name, err := IdentifierAt(path, line, column)
+		if err != nil {
+			return symbolSpec{}, err
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return symbolSpec{}, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		return symbolSpec{name: name, path: absPath, line: line, column: column}, nil
+	}
+
+	s := symbolSpec{name: spec}
+	if i := strings.LastIndex(spec, "."); i >= 0 {
+		s.qualifier, s.name = spec[:i], spec[i+1:]
+	}
+	if s.name == "" {
+		return symbolSpec{}, fmt.Errorf("invalid symbol %q", spec)
+	}
+	return s, nil
+}
+
+// matches reports whether the qualifier of s, if any, names the package of
+// obj, the type owner declaring it, or both.
+func (s symbolSpec) matches(obj types.Object, owner string) bool {
+	if s.qualifier == "" {
+		return true
+	}
+	if owner == "" {
+		return matchesGoPackage(s.qualifier, obj.Pkg())
+	}
+	if s.qualifier == owner {
+		return true
+	}
+	pkg, ok := strings.CutSuffix(s.qualifier, "."+owner)
+	return ok && matchesGoPackage(pkg, obj.Pkg())
+}
+
+// matchesGoPackage reports whether name is the name of pkg, its import path
+// or a trailing part of it.
+func matchesGoPackage(name string, pkg *types.Package) bool {
+	if pkg == nil {
+		return false
+	}
+	return name == pkg.Name() || name == pkg.Path() || strings.HasSuffix(pkg.Path(), "/"+name)
+}
+
+// goOwners returns the names of the types declaring the fields and methods
+// of files, by the position of their names.
+func goOwners(checks []*goCheck) map[token.Pos]string {
+	owners := make(map[token.Pos]string)
+	for _, c := range checks {
+		for _, file := range c.files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.TypeSpec:
+					var fields *ast.FieldList
+					switch t := n.Type.(type) {
+					case *ast.StructType:
+						fields = t.Fields
+					case *ast.InterfaceType:
+						fields = t.Methods
+					}
+					if fields == nil {
+						break
+					}
+					for _, field := range fields.List {
+						for _, name := range field.Names {
+							owners[name.Pos()] = n.Name.Name
+						}
+					}
+				case *ast.FuncDecl:
+					if n.Recv != nil && len(n.Recv.List) > 0 {
+						owners[n.Name.Pos()] = goTypeName(n.Recv.List[0].Type)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return owners
+}
+
+// planGoRename computes a type-checked rename of the package-level
+// declaration, method or struct field given by the symbol spec oldName that
+// is declared in a Go file under dir, or only in those of declared, by
+// absolute path, when it is not nil. References are renamed wherever they
+// appear in the enclosing module, while locals, comments, strings and
+// unrelated declarations sharing the name are left alone. It returns an
+// error rather than a change that would not compile because the new name
+// collides with or shadows another declaration, hides an exported name from
+// the packages using it or stops a type from implementing an interface, and
+// when the spec matches several declarations.
+func planGoRename(dir string, oldName string, newName string, declared map[string]bool) ([]Change, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	spec, err := parseSymbolSpec(oldName)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := loadGoModule(findGoModule(absDir))
+	if err != nil {
+		return nil, err
+	}
+	checks := m.checkAll()
+
+	r := &goRenamer{m: m, oldName: spec.name, newName: newName, targets: make(map[token.Pos]types.Object)}
+	var selected types.Object
+	if spec.path != "" {
+		if selected = r.objectAt(checks, spec); selected == nil {
+			return nil, nil
+		}
+		if !isRenamableGoObject(selected) {
+			return nil, fmt.Errorf("cannot rename %s: only package-level declarations, methods and fields can be renamed", oldName)
+		}
+	}
+	owners := goOwners(checks)
+	for _, c := range checks {
+		for ident, obj := range c.info.Defs {
+			if obj == nil || obj.Name() != spec.name || !isRenamableGoObject(obj) {
+				continue
+			}
+			if (selected != nil && obj.Pos() != selected.Pos()) || !spec.matches(obj, owners[obj.Pos()]) {
+				continue
+			}
+			filename := m.fset.Position(ident.Pos()).Filename
+			if declared != nil && !declared[filename] {
+				continue
+			}
+			if rel, err := filepath.Rel(absDir, filename); err != nil || isOutsideRel(rel) {
+				continue
+			}
+			r.targets[obj.Pos()] = obj
+		}
+	}
+	if len(r.targets) == 0 {
+		return nil, nil
+	}
+	if len(r.targets) > 1 {
+		var candidates []string
+		for pos, obj := range r.targets {
+			name := obj.Name()
+			if owner := owners[pos]; owner != "" {
+				name = owner + "." + name
+			}
+			// Columns count from 0, as in the positions vtk prints and takes
+			position := m.fset.Position(pos)
+			candidates = append(candidates, fmt.Sprintf("%s.%s at %s:%d:%d",
+				obj.Pkg().Path(), name, position.Filename, position.Line, position.Column-1))
+		}
+		sort.Strings(candidates)
+		return nil, fmt.Errorf("cannot rename %s: it is ambiguous, qualify it or give the file:line:column of one of\n  %s",
+			oldName, strings.Join(candidates, "\n  "))
+	}
+	if !token.IsIdentifier(newName) {
+		return nil, fmt.Errorf("cannot rename %s: %q is not a valid Go identifier", oldName, newName)
+	}
+
+	refs := make(map[token.Pos]bool)
+	for _, c := range checks {
+		if err := r.collect(c, refs); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.changes(absDir, dir, refs), nil
+}
+
+// objectAt returns the object declared or used by the identifier at the
+// position of spec, if any.
+func (r *goRenamer) objectAt(checks []*goCheck, spec symbolSpec) types.Object {
+	for _, c := range checks {
+		for _, idents := range []map[*ast.Ident]types.Object{c.info.Defs, c.info.Uses} {
+			for ident, obj := range idents {
+				position := r.m.fset.Position(ident.Pos())
+				column := position.Column - 1
+				if obj != nil && position.Filename == spec.path && position.Line == spec.line &&
+					column <= spec.column && spec.column <= column+len(ident.Name) {
+					return obj
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isRenamableGoObject reports whether obj is a declaration ReplaceSymbol
+// renames: anything declared at package level, a method or a struct field.
+func isRenamableGoObject(obj types.Object) bool {
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			return true
+		}
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return true
+		}
+	case *types.PkgName, *types.Label:
+		return false
+	}
+	return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope()
+}
+
+// collect adds the positions of the references to the targets in c to refs,
+// checking each one for conflicts.
+func (r *goRenamer) collect(c *goCheck, refs map[token.Pos]bool) error {
+	selectors := make(map[*ast.Ident]*ast.SelectorExpr)
+	for _, file := range c.files {
+		var err error
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				selectors[n.Sel] = n
+			case *ast.TypeSpec:
+				err = r.checkFieldConflicts(c, n)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, idents := range []map[*ast.Ident]types.Object{c.info.Defs, c.info.Uses} {
+		for ident, obj := range idents {
+			if obj == nil || obj.Name() != r.oldName || r.targets[obj.Pos()] == nil {
+				continue
+			}
+			if err := r.checkConflicts(c, ident, obj, selectors[ident]); err != nil {
+				return err
+			}
+			refs[ident.Pos()] = true
+		}
+	}
+	return r.checkInterfaces(c)
+}
+
+// checkConflicts reports whether renaming the reference ident to obj would
+// break the program checked in c. sel is the selector expression ident is
+// the selected name of, if any.
+func (r *goRenamer) checkConflicts(c *goCheck, ident *ast.Ident, obj types.Object, sel *ast.SelectorExpr) error {
+	conflict := func(other types.Object) error {
+		return fmt.Errorf("cannot rename %s to %s at %s: it would conflict with %s",
+			r.oldName, r.newName, r.m.fset.Position(ident.Pos()), r.describe(other))
+	}
+
+	if obj.Pkg() != nil && obj.Pkg().Path() != c.pkg.Path() && !token.IsExported(r.newName) {
+		return fmt.Errorf("cannot rename %s to %s: it is used from package %s at %s",
+			r.oldName, r.newName, c.pkg.Path(), r.m.fset.Position(ident.Pos()))
+	}
+
+	// A selected field or method must not collide with another one reachable
+	// through the same operand
+	if sel != nil {
+		if selection := c.info.Selections[sel]; selection != nil {
+			if other := r.lookupFieldOrMethod(selection.Recv(), c.pkg); other != nil {
+				return conflict(other)
+			}
+			return nil
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig := obj.Type().(*types.Signature); sig.Recv() != nil {
+			if other := r.lookupFieldOrMethod(sig.Recv().Type(), obj.Pkg()); other != nil {
+				return conflict(other)
+			}
+			return nil
+		}
+	case *types.Var:
+		if obj.IsField() {
+			return nil // Checked with the struct type declaring it
+		}
+	}
+
+	// A package-level name must not be shadowed where it is used, nor
+	// collide with another declaration of its package or an import
+	if sel == nil {
+		if scope := c.pkg.Scope().Innermost(ident.Pos()); scope != nil {
+			if _, other := scope.LookupParent(r.newName, ident.Pos()); other != nil && r.targets[other.Pos()] == nil {
+				return conflict(other)
+			}
+		}
+	}
+	if _, isDef := c.info.Defs[ident]; isDef {
+		if other := c.pkg.Scope().Lookup(r.newName); other != nil && r.targets[other.Pos()] == nil {
+			return conflict(other)
+		}
+		for _, file := range c.files {
+			if scope := c.info.Scopes[file]; scope != nil {
+				if other := scope.Lookup(r.newName); other != nil {
+					return conflict(other)
+				}
+			}
+		}
+		// Uses of a predeclared name would now refer to the renamed object
+		for use, other := range c.info.Uses {
+			if use.Name == r.newName && other.Parent() == types.Universe {
+				return conflict(other)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFieldConflicts reports whether renaming a field of the struct type
+// declared by spec would collide with another field or method of the type.
+func (r *goRenamer) checkFieldConflicts(c *goCheck, spec *ast.TypeSpec) error {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	renamed := false
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if obj := c.info.Defs[name]; obj != nil && r.targets[obj.Pos()] != nil {
+				renamed = true
+			}
+		}
+	}
+	named := c.info.Defs[spec.Name]
+	if !renamed || named == nil {
+		return nil
+	}
+	if other := r.lookupFieldOrMethod(named.Type(), c.pkg); other != nil {
+		return fmt.Errorf("cannot rename %s to %s in %s: it would conflict with %s",
+			r.oldName, r.newName, spec.Name.Name, r.describe(other))
+	}
+	return nil
+}
+
+// checkInterfaces reports whether renaming a method would stop a type from
+// implementing an interface it is converted to in c, implicitly by an
+// assignment, a call, a return, a composite literal, a send or a
+// comparison, or explicitly by a conversion or a type argument.
+func (r *goRenamer) checkInterfaces(c *goCheck) error {
+	methods := false
+	for _, obj := range r.targets {
+		if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+			methods = true
+		}
+	}
+	if !methods {
+		return nil
+	}
+
+	var err error
+	satisfy := func(pos token.Pos, T, V types.Type) {
+		if err == nil && T != nil && V != nil && types.AssignableTo(V, T) {
+			err = r.checkImplements(c, pos, T, V)
+		}
+	}
+	satisfyAll := func(pos token.Pos, Ts []types.Type, values []ast.Expr) {
+		for i, V := range r.valueTypes(c, values) {
+			if i < len(Ts) {
+				satisfy(pos, Ts[i], V)
+			}
+		}
+	}
+	returns := func(sig *types.Signature, body *ast.BlockStmt) {
+		if sig == nil || body == nil {
+			return
+		}
+		var results []types.Type
+		for i := 0; i < sig.Results().Len(); i++ {
+			results = append(results, sig.Results().At(i).Type())
+		}
+		// Returns of nested function literals are checked with their own
+		// signatures
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				satisfyAll(n.Pos(), results, n.Results)
+			}
+			return err == nil
+		})
+	}
+
+	for _, file := range c.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				var lhs []types.Type
+				for _, e := range n.Lhs {
+					lhs = append(lhs, c.info.TypeOf(e))
+				}
+				satisfyAll(n.Pos(), lhs, n.Rhs)
+			case *ast.ValueSpec:
+				if n.Type != nil {
+					T := c.info.TypeOf(n.Type)
+					lhs := make([]types.Type, len(n.Names))
+					for i := range lhs {
+						lhs[i] = T
+					}
+					satisfyAll(n.Pos(), lhs, n.Values)
+				}
+			case *ast.FuncDecl:
+				if obj := c.info.Defs[n.Name]; obj != nil {
+					sig, _ := obj.Type().(*types.Signature)
+					returns(sig, n.Body)
+				}
+			case *ast.FuncLit:
+				sig, _ := c.info.TypeOf(n).(*types.Signature)
+				returns(sig, n.Body)
+			case *ast.CallExpr:
+				if tv := c.info.Types[n.Fun]; tv.IsType() {
+					if len(n.Args) == 1 {
+						satisfy(n.Pos(), tv.Type, c.info.TypeOf(n.Args[0]))
+					}
+				} else if sig, ok := c.info.TypeOf(n.Fun).(*types.Signature); ok {
+					satisfyAll(n.Pos(), r.paramTypes(sig, len(n.Args), n.Ellipsis.IsValid()), n.Args)
+				}
+			case *ast.CompositeLit:
+				r.checkCompositeLit(c, n, satisfy)
+			case *ast.SendStmt:
+				if ch, ok := c.info.TypeOf(n.Chan).Underlying().(*types.Chan); ok {
+					satisfy(n.Pos(), ch.Elem(), c.info.TypeOf(n.Value))
+				}
+			case *ast.BinaryExpr:
+				if n.Op == token.EQL || n.Op == token.NEQ {
+					X, Y := c.info.TypeOf(n.X), c.info.TypeOf(n.Y)
+					satisfy(n.Pos(), X, Y)
+					satisfy(n.Pos(), Y, X)
+				}
+			case *ast.IndexExpr:
+				if X := c.info.TypeOf(n.X); X != nil {
+					if m, ok := X.Underlying().(*types.Map); ok {
+						satisfy(n.Pos(), m.Key(), c.info.TypeOf(n.Index))
+					}
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Type arguments must keep satisfying the constraints of their type
+	// parameters
+	for ident, inst := range c.info.Instances {
+		var tparams *types.TypeParamList
+		switch t := c.info.Uses[ident].Type().(type) {
+		case *types.Signature:
+			tparams = t.TypeParams()
+		case *types.Named:
+			tparams = t.TypeParams()
+		}
+		for i := 0; i < tparams.Len() && i < inst.TypeArgs.Len(); i++ {
+			constraint, V := tparams.At(i).Constraint(), inst.TypeArgs.At(i)
+			iface, ok := constraint.Underlying().(*types.Interface)
+			if ok && V != types.Typ[types.Invalid] && types.Satisfies(V, iface) {
+				if err := r.checkImplements(c, ident.Pos(), constraint, V); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkCompositeLit passes each element of lit to satisfy along with the
+// type of the field, element or key it initializes.
+func (r *goRenamer) checkCompositeLit(c *goCheck, lit *ast.CompositeLit, satisfy func(token.Pos, types.Type, types.Type)) {
+	typ := c.info.TypeOf(lit)
+	if typ == nil {
+		return
+	}
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	for i, elt := range lit.Elts {
+		kv, _ := elt.(*ast.KeyValueExpr)
+		value := elt
+		if kv != nil {
+			value = kv.Value
+		}
+		switch u := typ.Underlying().(type) {
+		case *types.Struct:
+			if kv != nil {
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					if field, ok := c.info.Uses[key].(*types.Var); ok {
+						satisfy(elt.Pos(), field.Type(), c.info.TypeOf(value))
+					}
+				}
+			} else if i < u.NumFields() {
+				satisfy(elt.Pos(), u.Field(i).Type(), c.info.TypeOf(value))
+			}
+		case *types.Slice:
+			satisfy(elt.Pos(), u.Elem(), c.info.TypeOf(value))
+		case *types.Array:
+			satisfy(elt.Pos(), u.Elem(), c.info.TypeOf(value))
+		case *types.Map:
+			if kv != nil {
+				satisfy(elt.Pos(), u.Key(), c.info.TypeOf(kv.Key))
+			}
+			satisfy(elt.Pos(), u.Elem(), c.info.TypeOf(value))
+		}
+	}
+}
+
+// valueTypes returns the types of the values of exprs, expanding a single
+// call returning several results.
+func (r *goRenamer) valueTypes(c *goCheck, exprs []ast.Expr) []types.Type {
+	if len(exprs) == 1 {
+		if tuple, ok := c.info.TypeOf(exprs[0]).(*types.Tuple); ok {
+			var result []types.Type
+			for i := 0; i < tuple.Len(); i++ {
+				result = append(result, tuple.At(i).Type())
+			}
+			return result
+		}
+	}
+	var result []types.Type
+	for _, e := range exprs {
+		result = append(result, c.info.TypeOf(e))
+	}
+	return result
+}
+
+// paramTypes returns the types the n arguments of a call to sig are
+// assigned to, spreading the variadic parameter unless the call passes a
+// slice to it with ellipsis.
+func (r *goRenamer) paramTypes(sig *types.Signature, n int, ellipsis bool) []types.Type {
+	params := sig.Params()
+	var result []types.Type
+	for i := 0; i < n && params.Len() > 0; i++ {
+		last := params.Len() - 1
+		switch {
+		case i < last:
+			result = append(result, params.At(i).Type())
+		case !sig.Variadic() || ellipsis:
+			if i == last {
+				result = append(result, params.At(i).Type())
+			}
+		default:
+			if s, ok := params.At(last).Type().(*types.Slice); ok {
+				result = append(result, s.Elem())
+			}
+		}
+	}
+	return result
+}
+
+// checkImplements reports whether V, which implements the interface T,
+// would no longer do so once its methods or those of T are renamed.
+func (r *goRenamer) checkImplements(c *goCheck, pos token.Pos, T, V types.Type) error {
+	iface, ok := T.Underlying().(*types.Interface)
+	if !ok || types.Identical(T, V) {
+		return nil
+	}
+	if _, ok := T.(*types.TypeParam); ok {
+		return nil
+	}
+	// A basic type can only implement interfaces without methods
+	if _, ok := V.(*types.Basic); ok {
+		return nil
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		name := m.Name()
+		if r.targets[m.Pos()] != nil {
+			name = r.newName
+		}
+		if method := r.methodAfterRename(V, m.Pkg(), name); method == nil || !types.Identical(method.Type(), m.Type()) {
+			qualifier := func(pkg *types.Package) string {
+				if pkg == c.pkg {
+					return ""
+				}
+				return pkg.Name()
+			}
+			return fmt.Errorf("cannot rename %s to %s: %s would no longer implement %s at %s",
+				r.oldName, r.newName, types.TypeString(V, qualifier), types.TypeString(T, qualifier), r.m.fset.Position(pos))
+		}
+	}
+	return nil
+}
+
+// methodAfterRename returns the method in the method set of typ that will be
+// named name once the targets are renamed.
+func (r *goRenamer) methodAfterRename(typ types.Type, pkg *types.Package, name string) types.Object {
+	mset := types.NewMethodSet(typ)
+	if name == r.newName {
+		if sel := mset.Lookup(pkg, r.oldName); sel != nil && r.targets[sel.Obj().Pos()] != nil {
+			return sel.Obj()
+		}
+	}
+	sel := mset.Lookup(pkg, name)
+	if sel == nil || r.targets[sel.Obj().Pos()] != nil {
+		return nil
+	}
+	return sel.Obj()
+}
+
+// lookupFieldOrMethod returns the field or method named newName of typ, if
+// it is not itself being renamed.
+func (r *goRenamer) lookupFieldOrMethod(typ types.Type, pkg *types.Package) types.Object {
+	other, _, _ := types.LookupFieldOrMethod(typ, true, pkg, r.newName)
+	if other == nil || r.targets[other.Pos()] != nil {
+		return nil
+	}
+	return other
+}
+
+// describe names obj and where it was declared for an error message.
+func (r *goRenamer) describe(obj types.Object) string {
+	if obj.Parent() == types.Universe {
+		return "predeclared " + obj.Name()
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return fmt.Sprintf("import %s at %s", obj.Name(), r.m.fset.Position(obj.Pos()))
+	}
+	return fmt.Sprintf("%s declared at %s", obj.Name(), r.m.fset.Position(obj.Pos()))
+}
+
+// changes rewrites the identifiers at refs. Paths are reported relative to
+// dir as the caller gave it, like the paths of a walk of dir.
+func (r *goRenamer) changes(absDir string, dir string, refs map[token.Pos]bool) []Change {
+	byFile := make(map[string][]token.Position)
+	for pos := range refs {
+		position := r.m.fset.Position(pos)
+		byFile[position.Filename] = append(byFile[position.Filename], position)
+	}
+
+	var changes []Change
+	for filename, positions := range byFile {
+		sort.Slice(positions, func(i, j int) bool { return positions[i].Offset < positions[j].Offset })
+
+		path := filename
+		if rel, err := filepath.Rel(absDir, filename); err == nil {
+			path = filepath.Join(dir, rel)
+		}
+
+		content := r.m.content[filename]
+		var output bytes.Buffer
+		var results []Result
+		last := 0
+		for _, position := range positions {
+			output.Write(content[last:position.Offset])
+			output.WriteString(r.newName)
+			last = position.Offset + len(r.oldName)
+			results = append(results, Result{
+				Path:   path,
+				Line:   position.Line,
+				Column: position.Column - 1,
+				Match:  r.newName,
+			})
+		}
+		output.Write(content[last:])
+
+		changes = append(changes, Change{
+			Path:    path,
+			Old:     content,
+			New:     output.Bytes(),
+			Results: results,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
@@ -0,0 +1,339 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeGoModule creates a small module exercising cross-package references.
+func writeGoModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"lib/lib.go": `package lib
+
+// Count returns the number of items. Count stays as is in comments.
+func Count(items []string) int { return len(items) }
+
+type Store struct {
+	Size int
+}
+
+func (s *Store) Len() int { return s.Size }
+
+func use() int {
+	total := 1
+	return Count(nil) + total
+}
+`,
+		"lib/lib_test.go": `package lib_test
+
+import "example.com/m/lib"
+
+var empty = lib.Count(nil) == 0
+`,
+		"app/main.go": `package main
+
+import (
+	"strings"
+
+	"example.com/m/lib"
+)
+
+func main() {
+	Count := 3 // an unrelated local
+	s := &lib.Store{Size: 2}
+	println(lib.Count(nil), Count, strings.ToLower("Count"), s.Size, s.Len())
+}
+`,
+		"other/other.go": `package other
+
+func Count() int { return 0 }
+`,
+	})
+	return root
+}
+
+// applyGoRename plans a rename in dir and returns the new content by path
+// relative to root.
+func applyGoRename(t *testing.T, root string, dir string, oldName string, newName string) map[string]string {
+	t.Helper()
+	changes, err := planGoRename(filepath.Join(root, dir), oldName, newName, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := make(map[string]string)
+	for _, change := range changes {
+		rel, _ := filepath.Rel(root, change.Path)
+		files[filepath.ToSlash(rel)] = string(change.New)
+	}
+	return files
+}
+
+func TestPlanGoRename_CrossPackage(t *testing.T) {
+	root := writeGoModule(t)
+
+	files := applyGoRename(t, root, "lib", "Count", "Total")
+	if len(files) != 3 {
+		t.Fatalf("expected 3 changed files, got %v", files)
+	}
+
+	lib := files["lib/lib.go"]
+	if !strings.Contains(lib, "func Total(items []string) int") || !strings.Contains(lib, "return Total(nil) + total") {
+		t.Errorf("expected declaration and use to be renamed:\n%s", lib)
+	}
+	if !strings.Contains(lib, "// Count returns the number of items. Count stays") {
+		t.Errorf("expected comment to be left alone:\n%s", lib)
+	}
+
+	main := files["app/main.go"]
+	if !strings.Contains(main, `println(lib.Total(nil), Count, strings.ToLower("Count")`) || !strings.Contains(main, "Count := 3") {
+		t.Errorf("expected only the qualified reference to be renamed:\n%s", main)
+	}
+
+	if !strings.Contains(files["lib/lib_test.go"], "var empty = lib.Total(nil) == 0") {
+		t.Errorf("expected external test to be renamed:\n%s", files["lib/lib_test.go"])
+	}
+
+	// The same name in a package outside the directory is not a target
+	if changes, err := planGoRename(filepath.Join(root, "other"), "Missing", "Other", nil); err != nil || changes != nil {
+		t.Errorf("expected no changes for an undeclared name, got %v, %v", changes, err)
+	}
+}
+
+func TestPlanGoRename_FieldsAndMethods(t *testing.T) {
+	root := writeGoModule(t)
+
+	files := applyGoRename(t, root, "lib", "Size", "Capacity")
+	if !strings.Contains(files["lib/lib.go"], "Capacity int") || !strings.Contains(files["lib/lib.go"], "return s.Capacity") {
+		t.Errorf("expected field and selector to be renamed:\n%s", files["lib/lib.go"])
+	}
+	if !strings.Contains(files["app/main.go"], "&lib.Store{Capacity: 2}") || !strings.Contains(files["app/main.go"], "s.Capacity, s.Len()") {
+		t.Errorf("expected composite literal key and selector to be renamed:\n%s", files["app/main.go"])
+	}
+
+	files = applyGoRename(t, root, "lib", "Len", "Length")
+	if !strings.Contains(files["lib/lib.go"], "func (s *Store) Length() int") || !strings.Contains(files["app/main.go"], "s.Length()") {
+		t.Errorf("expected method and call to be renamed:\n%v", files)
+	}
+}
+
+func TestPlanGoRename_Conflicts(t *testing.T) {
+	root := writeGoModule(t)
+	os.WriteFile(filepath.Join(root, "lib", "extra.go"), []byte(`package lib
+
+var Existing = 1
+
+func local() int { return 0 }
+
+func caller() []int {
+	total := 1
+	return make([]int, local()+total)
+}
+`), 0644)
+
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		errText string
+	}{
+		{"shadowed by local", "local", "total", "total declared at"},
+		{"package-level clash", "Count", "Existing", "Existing declared at"},
+		{"predeclared identifier", "local", "len", "predeclared len"},
+		{"unexported across packages", "Count", "count", "used from package"},
+		{"field clashes with method", "Size", "Len", "Len declared at"},
+		{"method clashes with field", "Len", "Size", "Size declared at"},
+		{"invalid identifier", "Count", "not-valid", "not a valid Go identifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := planGoRename(filepath.Join(root, "lib"), tt.oldName, tt.newName, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestPlanGoRename_Interfaces(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"file/file.go": `package file
+
+import "io"
+
+type F struct{}
+
+func (F) Close() error { return nil }
+
+var _ io.Closer = F{}
+`,
+		"conv/conv.go": `package conv
+
+import "io"
+
+type F struct{}
+
+func (*F) Close() error { return nil }
+
+func closers() []io.Closer { return []io.Closer{&F{}} }
+`,
+		"shape/shape.go": `package shape
+
+type Shape interface{ Area() int }
+
+func Total(shapes ...Shape) int { return len(shapes) }
+`,
+		"square/square.go": `package square
+
+import "example.com/m/shape"
+
+type Square struct{}
+
+func (Square) Area() int { return 1 }
+
+func total() int { return shape.Total(Square{}) }
+`,
+	})
+
+	tests := []struct {
+		name    string
+		dir     string
+		oldName string
+		newName string
+		errText string
+	}{
+		{"variable declaration", "file", "Close", "Shut", "F would no longer implement io.Closer"},
+		{"composite literal", "conv", "Close", "Shut", "*F would no longer implement io.Closer"},
+		{"interface method", "shape", "Area", "Size", "Square would no longer implement shape.Shape"},
+		{"method of implementation", "square", "Area", "Size", "Square would no longer implement shape.Shape"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := planGoRename(filepath.Join(root, tt.dir), tt.oldName, tt.newName, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestPlanGoRename_Ambiguous(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"a/a.go": `package a
+
+func Helper() int { return 1 }
+
+type User struct{ Name string }
+
+type Team struct{ Name string }
+`,
+		"b/b.go": `package b
+
+import "example.com/m/a"
+
+func Helper() int { return a.Helper() }
+`,
+	})
+
+	paths := func(changes []Change) string {
+		var rels []string
+		for _, change := range changes {
+			rel, _ := filepath.Rel(root, change.Path)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		return strings.Join(rels, ",")
+	}
+
+	// A name declared more than once is an error listing the candidates
+	_, err := planGoRename(root, "Helper", "Assist", nil)
+	if err == nil || !strings.Contains(err.Error(), "example.com/m/a.Helper at "+filepath.Join(root, "a", "a.go")+":3:5") ||
+		!strings.Contains(err.Error(), "example.com/m/b.Helper at "+filepath.Join(root, "b", "b.go")+":5:5") {
+		t.Errorf("expected an error listing both helpers, got %v", err)
+	}
+	_, err = planGoRename(root, "Name", "Title", nil)
+	if err == nil || !strings.Contains(err.Error(), "a.User.Name") || !strings.Contains(err.Error(), "a.Team.Name") {
+		t.Errorf("expected an error listing both fields, got %v", err)
+	}
+
+	tests := []struct {
+		spec  string
+		paths string
+		text  string
+	}{
+		{spec: "a.Helper", paths: "a/a.go,b/b.go", text: "return a.Assist()"},
+		{spec: "example.com/m/b.Helper", paths: "b/b.go", text: "func Assist() int"},
+		{spec: filepath.Join(root, "b", "b.go") + ":5:30", paths: "a/a.go,b/b.go", text: "return a.Assist()"},
+		{spec: filepath.Join(root, "b", "b.go") + ":5:6", paths: "b/b.go", text: "func Assist() int"},
+		{spec: "User.Name", paths: "a/a.go", text: "type User struct{ Assist string }"},
+		{spec: "a.Team.Name", paths: "a/a.go", text: "type Team struct{ Assist string }"},
+	}
+	for _, tt := range tests {
+		changes, err := planGoRename(root, tt.spec, "Assist", nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if got := paths(changes); got != tt.paths {
+			t.Errorf("%s: expected %s, got %s", tt.spec, tt.paths, got)
+		}
+		var content string
+		for _, change := range changes {
+			content += string(change.New)
+		}
+		if !strings.Contains(content, tt.text) || strings.Count(content, "Assist") != strings.Count(tt.paths, ",")+1 {
+			t.Errorf("%s: expected a single rename including %q, got:\n%s", tt.spec, tt.text, content)
+		}
+	}
+
+	// A directory holding one of the declarations selects it
+	changes, err := planGoRename(filepath.Join(root, "b"), "Helper", "Assist", nil)
+	if got := paths(changes); err != nil || got != "b/b.go" {
+		t.Errorf("expected b/b.go, got %s, %v", got, err)
+	}
+}
+
+func TestPlanReplaceSymbol_GoPathFilters(t *testing.T) {
+	root := writeGoModule(t)
+	lib := filepath.Join(root, "lib")
+
+	// References outside the searched directory are renamed whatever the
+	// filters, since they are not under it
+	changes, err := PlanReplaceSymbol(lib, "Count", "Total", Options{Include: []string{"*.go"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changed files, got %d", len(changes))
+	}
+
+	// A filter that would leave a reference behind is an error naming it
+	tests := []struct {
+		dir  string
+		opts Options
+		file string
+	}{
+		{dir: lib, opts: Options{Exclude: []string{"*_test.go"}}, file: filepath.Join(lib, "lib_test.go")},
+		{dir: root, opts: Options{Include: []string{"lib/"}}, file: filepath.Join(root, "app", "main.go")},
+	}
+	for _, tt := range tests {
+		_, err := PlanReplaceSymbol(tt.dir, "Count", "Total", tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.file+" refers to it") {
+			t.Errorf("%+v: expected an error naming %s, got %v", tt.opts, tt.file, err)
+		}
+	}
+
+	// Filtering out the declarations renames nothing
+	changes, err = PlanReplaceSymbol(lib, "Count", "Total", Options{Exclude: []string{"lib.go"}})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %d, %v", len(changes), err)
+	}
+}
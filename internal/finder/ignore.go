@@ -0,0 +1,312 @@
+package finder
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// ignoreRule is a single compiled line from an ignore file.
+type ignoreRule struct {
+	pattern *ignore.GitIgnore
+	negate  bool
+}
+
+// ignoreRules holds the patterns from one ignore file. Patterns are kept
+// one per line so that the last matching pattern decides, as it does in git.
+type ignoreRules struct {
+	base  string // directory the patterns are relative to
+	rules []ignoreRule
+}
+
+// loadIgnoreRules compiles the ignore file at path, with patterns relative
+// to base. It returns nil if the file does not exist or cannot be read.
+func loadIgnoreRules(base string, path string) *ignoreRules {
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return compileIgnoreRules(base, strings.Split(string(content), "\n"))
+}
+
+// compileIgnoreRules compiles gitignore-syntax lines relative to base.
+func compileIgnoreRules(base string, lines []string) *ignoreRules {
+	r := &ignoreRules{base: base}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Negation is tracked here rather than by the library, which only
+		// un-ignores paths matched earlier in the same file.
+		negate := strings.HasPrefix(line, "!")
+		if negate {
+			line = line[1:]
+		}
+
+		r.rules = append(r.rules, ignoreRule{
+			pattern: ignore.CompileIgnoreLines(line),
+			negate:  negate,
+		})
+	}
+	return r
+}
+
+// match reports whether any pattern applies to the absolute path, and if so
+// whether the last applicable pattern ignores it.
+func (r *ignoreRules) match(path string, isDir bool) (matched bool, ignored bool) {
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil || rel == "." || isOutsideRel(rel) {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// Trailing slash lets directory-only patterns ("build/") match
+	if isDir {
+		rel += "/"
+	}
+
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		if r.rules[i].pattern.MatchesPath(rel) {
+			return true, !r.rules[i].negate
+		}
+	}
+	return false, false
+}
+
+// isOutsideRel reports whether rel, a path made relative by filepath.Rel,
+// leads outside its base directory. Names that merely start with two dots,
+// like "..foo", are inside it.
+func isOutsideRel(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// vtkIgnoreName is the name of vtk's own ignore files. They use gitignore
+// syntax and exclude paths from searches without affecting git.
+const vtkIgnoreName = ".vtkignore"
+
+// ignoreMatcher applies git's layered ignore rules to paths under a search
+// directory. In order of increasing precedence these are core.excludesFile,
+// .git/info/exclude and every .gitignore and .vtkignore from the repository
+// root down to the directory containing the path. Within a directory, the
+// .vtkignore takes precedence over the .gitignore.
+type ignoreMatcher struct {
+	dir    string // search directory as given by the caller
+	absDir string // absolute form of dir
+	root   string // repository root, or absDir outside a repository
+	global []*ignoreRules
+	cache  *Cache // compiled ignore files shared between searches, if any
+
+	mu   sync.Mutex
+	dirs map[string]*ignoreRules // per-directory ignore files, nil when absent
+}
+
+// newIgnoreMatcher builds a matcher for a search rooted at dir. When dir is
+// inside a git repository, the repository's exclude files and any .gitignore
+// above dir are honored too. Ignore files are compiled through cache, which
+// may be nil.
+func newIgnoreMatcher(dir string, cache *Cache) *ignoreMatcher {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		absDir = dir
+	}
+
+	root, gitDir := findRepoRoot(absDir)
+	m := &ignoreMatcher{
+		dir:    dir,
+		absDir: absDir,
+		root:   root,
+		cache:  cache,
+		dirs:   make(map[string]*ignoreRules),
+	}
+
+	if gitDir != "" {
+		if r := cache.ignoreRules(root, globalExcludesFile(gitDir)); r != nil {
+			m.global = append(m.global, r)
+		}
+		if r := cache.ignoreRules(root, filepath.Join(gitDir, "info", "exclude")); r != nil {
+			m.global = append(m.global, r)
+		}
+	}
+
+	return m
+}
+
+// Match reports whether path, a file or directory found while walking the
+// search directory, is ignored.
+func (m *ignoreMatcher) Match(path string, isDir bool) bool {
+	// Git's and vtk's own state are never part of a search
+	if isDir && (filepath.Base(path) == stateDirName || filepath.Base(path) == ".git") {
+		return true
+	}
+
+	abs := path
+	if !filepath.IsAbs(path) {
+		rel, err := filepath.Rel(m.dir, path)
+		if err != nil {
+			return false
+		}
+		abs = filepath.Join(m.absDir, rel)
+	}
+
+	// Deeper .gitignore files take precedence over shallower ones
+	for d := filepath.Dir(abs); ; d = filepath.Dir(d) {
+		if r := m.rulesFor(d); r != nil {
+			if matched, ignored := r.match(abs, isDir); matched {
+				return ignored
+			}
+		}
+		if d == m.root || d == filepath.Dir(d) {
+			break
+		}
+	}
+
+	for i := len(m.global) - 1; i >= 0; i-- {
+		if matched, ignored := m.global[i].match(abs, isDir); matched {
+			return ignored
+		}
+	}
+
+	return false
+}
+
+// rulesFor returns the compiled .gitignore and .vtkignore of dir, loading
+// them on first use.
+func (m *ignoreMatcher) rulesFor(dir string) *ignoreRules {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.dirs[dir]
+	if !ok {
+		r = m.cache.ignoreRules(dir, filepath.Join(dir, ".gitignore"))
+		if vtk := m.cache.ignoreRules(dir, filepath.Join(dir, vtkIgnoreName)); vtk != nil {
+			// The last matching pattern decides, so .vtkignore goes last
+			merged := &ignoreRules{base: dir}
+			if r != nil {
+				merged.rules = append(merged.rules, r.rules...)
+			}
+			merged.rules = append(merged.rules, vtk.rules...)
+			r = merged
+		}
+		m.dirs[dir] = r
+	}
+	return r
+}
+
+// findRepoRoot walks up from dir looking for a .git entry. It returns the
+// repository root and git directory, or dir and "" outside a repository.
+func findRepoRoot(dir string) (root string, gitDir string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		gitPath := filepath.Join(d, ".git")
+		info, err := os.Stat(gitPath)
+		if err == nil {
+			if info.IsDir() {
+				return d, gitPath
+			}
+			// Worktrees and submodules use a ".git" file pointing elsewhere
+			if target := readGitFile(gitPath); target != "" {
+				return d, target
+			}
+		}
+		if d == filepath.Dir(d) {
+			return dir, ""
+		}
+	}
+}
+
+// readGitFile returns the git directory named by a "gitdir: <path>" file.
+func readGitFile(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	line := strings.TrimSpace(string(content))
+	target, ok := strings.CutPrefix(line, "gitdir:")
+	if !ok {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return target
+}
+
+// globalExcludesFile returns the path configured as core.excludesFile, or
+// git's default of $XDG_CONFIG_HOME/git/ignore when it is not set.
+func globalExcludesFile(gitDir string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	// Later files override earlier ones, as in git
+	var configs []string
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	configs = append(configs, filepath.Join(gitDir, "config"))
+
+	excludesFile := ""
+	for _, config := range configs {
+		if value := readCoreExcludesFile(config); value != "" {
+			excludesFile = value
+		}
+	}
+
+	if excludesFile == "" {
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if rest, ok := strings.CutPrefix(excludesFile, "~/"); ok && home != "" {
+		return filepath.Join(home, rest)
+	}
+	return excludesFile
+}
+
+// readCoreExcludesFile extracts core.excludesFile from a git config file.
+func readCoreExcludesFile(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	value := ""
+	inCore := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section := strings.Trim(line, "[] \t")
+			inCore = strings.EqualFold(section, "core")
+			continue
+		}
+		if !inCore {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return value
+}
@@ -0,0 +1,209 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// writeTree creates the given files under root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+}
+
+// globNames returns the paths, hidden ones included, that GlobFiles finds
+// relative to dir, sorted.
+func globNames(t *testing.T, dir string) []string {
+	t.Helper()
+	results, err := collect(func(emit func(Result) error) error {
+		return GlobFilesStream(t.Context(), dir, ".*", Options{Hidden: true}, emit)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, r := range results {
+		rel, _ := filepath.Rel(dir, r.Path)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertNames(t *testing.T, got []string, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestIgnoreMatcher_Layers(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	repo := filepath.Join(tempDir, "repo")
+	writeTree(t, tempDir, map[string]string{
+		"config/git/ignore":         "*.swp\n",
+		"repo/.git/info/exclude":    "scratch/\n",
+		"repo/.gitignore":           "*.log\nbuild/\n",
+		"repo/keep.txt":             "keep",
+		"repo/debug.log":            "ignored by root .gitignore",
+		"repo/notes.swp":            "ignored by global excludes",
+		"repo/scratch/tmp.txt":      "ignored by info/exclude",
+		"repo/build/out.txt":        "ignored directory",
+		"repo/pkg/.gitignore":       "!important.log\ngenerated.go\n",
+		"repo/pkg/important.log":    "re-included by nested .gitignore",
+		"repo/pkg/other.log":        "still ignored",
+		"repo/pkg/generated.go":     "ignored by nested .gitignore",
+		"repo/pkg/main.go":          "package pkg",
+		"repo/pkg/sub/generated.go": "ignored by ancestor .gitignore",
+	})
+
+	assertNames(t, globNames(t, repo), []string{
+		".gitignore",
+		"keep.txt",
+		"pkg/.gitignore",
+		"pkg/important.log",
+		"pkg/main.go",
+	})
+}
+
+func TestIgnoreMatcher_VtkIgnore(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	repo := filepath.Join(tempDir, "repo")
+	writeTree(t, tempDir, map[string]string{
+		"repo/.git/HEAD":          "ref: refs/heads/main",
+		"repo/.gitignore":         "*.log\n",
+		"repo/.vtkignore":         "fixtures/\n!keep.log\n",
+		"repo/main.go":            "package main",
+		"repo/debug.log":          "ignored by .gitignore",
+		"repo/keep.log":           "re-included by .vtkignore",
+		"repo/fixtures/big.json":  "ignored by .vtkignore",
+		"repo/pkg/.gitignore":     "!*.gen.go\n",
+		"repo/pkg/.vtkignore":     "*.gen.go\n",
+		"repo/pkg/api.gen.go":     "the .vtkignore wins in its directory",
+		"repo/pkg/api.go":         "package pkg",
+		"repo/pkg/sub/.vtkignore": "!*.gen.go\n",
+		"repo/pkg/sub/sub.gen.go": "re-included by a deeper .vtkignore",
+	})
+
+	assertNames(t, globNames(t, repo), []string{
+		".gitignore",
+		".vtkignore",
+		"keep.log",
+		"main.go",
+		"pkg/.gitignore",
+		"pkg/.vtkignore",
+		"pkg/api.go",
+		"pkg/sub/.vtkignore",
+		"pkg/sub/sub.gen.go",
+	})
+}
+
+func TestIgnoreMatcher_SubdirectorySearch(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	writeTree(t, tempDir, map[string]string{
+		"repo/.git/HEAD":                  "ref: refs/heads/main",
+		"repo/.gitignore":                 "vendor/\n/internal/app/*.gen.go\n",
+		"repo/internal/app/app.go":        "package app",
+		"repo/internal/app/app.gen.go":    "package app",
+		"repo/internal/app/vendor/lib.go": "package lib",
+	})
+
+	// The governing .gitignore lives above the search directory
+	dir := filepath.Join(tempDir, "repo", "internal", "app")
+	assertNames(t, globNames(t, dir), []string{"app.go"})
+
+	// Relative search directories resolve against the same rules
+	oldDir, _ := os.Getwd()
+	defer os.Chdir(oldDir)
+	os.Chdir(filepath.Join(tempDir, "repo", "internal"))
+	assertNames(t, globNames(t, "app"), []string{"app.go"})
+}
+
+func TestIgnoreMatcher_CoreExcludesFile(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	writeTree(t, tempDir, map[string]string{
+		".gitconfig":           "[user]\n\tname = test\n[core]\n\texcludesFile = ~/my-excludes\n",
+		"my-excludes":          "*.bak\n",
+		"repo/.git/HEAD":       "ref: refs/heads/main",
+		"repo/file.txt":        "text",
+		"repo/file.txt.bak":    "backup",
+		"repo/nested/old.bak":  "backup",
+		"repo/nested/file.txt": "text",
+	})
+
+	assertNames(t, globNames(t, filepath.Join(tempDir, "repo")), []string{
+		"file.txt",
+		"nested/file.txt",
+	})
+}
+
+func TestIgnoreMatcher_OutsideRepository(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	// Global excludes only apply inside a repository
+	writeTree(t, tempDir, map[string]string{
+		"config/git/ignore":   "*.txt\n",
+		"tree/.gitignore":     "out/\n",
+		"tree/a.txt":          "a",
+		"tree/out/b.txt":      "b",
+		"tree/sub/.gitignore": "c.txt\n",
+		"tree/sub/c.txt":      "c",
+		"tree/sub/d.txt":      "d",
+	})
+
+	assertNames(t, globNames(t, filepath.Join(tempDir, "tree")), []string{
+		".gitignore",
+		"a.txt",
+		"sub/.gitignore",
+		"sub/d.txt",
+	})
+}
+
+func TestIgnoreMatcher_DotDotNames(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tempDir, "config"))
+
+	// Names starting with two dots are inside the tree, so rules apply to them
+	writeTree(t, tempDir, map[string]string{
+		"tree/.gitignore":       "*.log\n...config/\n",
+		"tree/..notes.log":      "ignored",
+		"tree/...config/a.txt":  "ignored",
+		"tree/..keep/b.txt":     "kept",
+		"tree/..keep/debug.log": "ignored",
+	})
+
+	assertNames(t, globNames(t, filepath.Join(tempDir, "tree")), []string{
+		"..keep/b.txt",
+		".gitignore",
+	})
+}
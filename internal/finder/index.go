@@ -0,0 +1,305 @@
+package finder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// symbolIndexVersion is bumped whenever symbol extraction changes so that
+// indexes built by older versions are rebuilt rather than trusted.
+const symbolIndexVersion = 1
+
+// symbolIndexName is the file holding the symbol index in the index
+// directory of the state directory.
+const symbolIndexName = "symbols.json"
+
+// racyWindow is how close to the time an index was saved a file may have
+// been modified for its modification time to be untrustworthy. Files
+// modified within it are hashed before their symbols are reused, since
+// coarse file system timestamps could hide a later change.
+const racyWindow = time.Second
+
+// IndexStats summarizes an update of the symbol or text index.
+type IndexStats struct {
+	Files   int // files in the index
+	Symbols int // symbols in the index, for the symbol index
+	Parsed  int // files parsed because they were new or had changed
+	Removed int // files dropped because they no longer exist
+}
+
+// symbolIndex caches the symbols of the code files of a tree on disk, in
+// the state directory of the tree's repository. Files are keyed by their
+// slash-separated path relative to the repository root, and their symbols
+// are reused for as long as their modification time, size or content hash
+// show them unchanged. It is safe for concurrent use.
+type symbolIndex struct {
+	root string // directory the keys are relative to
+	path string // file the index is stored in
+
+	// memoryOnly is set for the indexes a Cache keeps for trees that were
+	// not indexed, which are never written to disk.
+	memoryOnly bool
+
+	mu     sync.Mutex
+	data   symbolIndexData
+	parsed int
+	dirty  bool
+}
+
+// symbolIndexData is the on-disk form of a symbol index.
+type symbolIndexData struct {
+	Version int                     `json:"version"`
+	Saved   time.Time               `json:"saved"`
+	Files   map[string]*indexedFile `json:"files"`
+}
+
+// indexedFile is the index entry of a single file.
+type indexedFile struct {
+	Stamp   fileStamp `json:"stamp"`
+	Symbols []Symbol  `json:"symbols"`
+}
+
+// fileStamp identifies the version of a file an index entry was built from.
+type fileStamp struct {
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"`
+}
+
+// BuildSymbolIndex creates or updates the symbol index for the tree under
+// dir, parsing only the files that are new or changed since the last
+// update and dropping files that no longer exist. Later symbol searches of
+// dir or any directory below it reuse the index. Files are parsed on a
+// pool of opts.Workers goroutines.
+func BuildSymbolIndex(dir string, opts Options) (*IndexStats, error) {
+	return loadSymbolIndex(dir).update(dir, opts, nil)
+}
+
+// update brings the entries of the files under dir up to date and saves the
+// index, calling visit, when it is not nil, with the symbols of every file
+// found. visit may be called concurrently.
+func (idx *symbolIndex) update(dir string, opts Options, visit func(path string, symbols []Symbol)) (*IndexStats, error) {
+	// Check if directory exists
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("directory does not exist: %s", dir)
+	}
+
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+
+	err := streamFiles(context.Background(), Options{Workers: opts.Workers}, walkSymbolFiles(dir, opts.Cache, nil), func(path string) ([]Result, error) {
+		symbols, err := idx.symbols(path)
+		if err != nil {
+			return nil, err
+		}
+		if visit != nil {
+			visit(path, symbols)
+		}
+		mu.Lock()
+		seen[idx.key(path)] = true
+		mu.Unlock()
+		return nil, nil
+	}, func(Result) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the files under dir that the walk no longer found
+	stats := &IndexStats{Parsed: idx.parsed}
+	for _, key := range staleKeys(idx.data.Files, idx.key(dir), seen) {
+		delete(idx.data.Files, key)
+		stats.Removed++
+	}
+
+	for _, file := range idx.data.Files {
+		stats.Files++
+		stats.Symbols += len(file.Symbols)
+	}
+
+	// Save even an unchanged index so that later searches find it
+	idx.dirty = true
+	if err := idx.save(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// openSymbolIndex returns the symbol index covering dir, or nil if none was
+// built.
+func openSymbolIndex(dir string) *symbolIndex {
+	index := loadSymbolIndex(dir)
+	if _, err := os.Stat(index.path); err != nil {
+		return nil
+	}
+	return index
+}
+
+// loadSymbolIndex reads the symbol index covering dir. A missing, unreadable
+// or outdated index yields an empty one to be rebuilt.
+func loadSymbolIndex(dir string) *symbolIndex {
+	root := stateRoot(dir)
+	index := &symbolIndex{
+		root: root,
+		path: filepath.Join(root, stateDirName, "index", symbolIndexName),
+	}
+
+	if data, err := os.ReadFile(index.path); err == nil {
+		if json.Unmarshal(data, &index.data) != nil || index.data.Version != symbolIndexVersion {
+			index.data = symbolIndexData{}
+		}
+	}
+	if index.data.Files == nil {
+		index.data = symbolIndexData{Version: symbolIndexVersion, Files: make(map[string]*indexedFile)}
+	}
+	return index
+}
+
+// key returns the index key of path.
+func (idx *symbolIndex) key(path string) string {
+	return indexKey(idx.root, path)
+}
+
+// symbols returns the symbols of the file at path, from the index if the
+// file is unchanged since it was indexed and by parsing it otherwise.
+func (idx *symbolIndex) symbols(path string) ([]Symbol, error) {
+	key := idx.key(path)
+	idx.mu.Lock()
+	entry := idx.data.Files[key]
+	saved := idx.data.Saved
+	idx.mu.Unlock()
+
+	var old *fileStamp
+	if entry != nil {
+		old = &entry.Stamp
+	}
+	stamp, content, changed, err := refreshStamp(path, old, saved)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		// A touched but unchanged file only needs its entry refreshed
+		if stamp != entry.Stamp {
+			idx.store(key, &indexedFile{Stamp: stamp, Symbols: entry.Symbols}, false)
+		}
+		return entry.Symbols, nil
+	}
+
+	symbols, err := extractSymbolsFromContent(path, content)
+	if err != nil {
+		return nil, err
+	}
+	idx.store(key, &indexedFile{Stamp: stamp, Symbols: symbols}, true)
+	return symbols, nil
+}
+
+// store records the entry of the file with the given key.
+func (idx *symbolIndex) store(key string, entry *indexedFile, parsed bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.data.Files[key] = entry
+	idx.dirty = true
+	if parsed {
+		idx.parsed++
+	}
+}
+
+// save writes the index back to disk if it changed.
+func (idx *symbolIndex) save() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.dirty {
+		return nil
+	}
+
+	idx.data.Saved = time.Now()
+	if idx.memoryOnly {
+		idx.dirty = false
+		return nil
+	}
+	data, err := json.Marshal(idx.data)
+	if err != nil {
+		return fmt.Errorf("failed to encode symbol index: %w", err)
+	}
+	if err := writeIndexFile(idx.root, symbolIndexName, data); err != nil {
+		return fmt.Errorf("failed to write symbol index: %w", err)
+	}
+
+	idx.dirty = false
+	return nil
+}
+
+// refreshStamp checks the file at path against the stamp old of its entry
+// in an index saved at saved, where a nil stamp means the file is not
+// indexed. It returns the current stamp of the file and, when the entry
+// must be rebuilt, changed is true and content holds the file's content.
+//
+// A file whose modification time and size match its stamp is unchanged
+// unless it was modified within racyWindow of the save. Otherwise its
+// content is hashed, so that files that were only touched are not
+// reprocessed.
+func refreshStamp(path string, old *fileStamp, saved time.Time) (stamp fileStamp, content []byte, changed bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, nil, false, err
+	}
+	stamp = fileStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+
+	racy := saved.Sub(info.ModTime()) < racyWindow
+	if old != nil && old.ModTime == stamp.ModTime && old.Size == stamp.Size && !racy {
+		return *old, nil, false, nil
+	}
+
+	content, err = os.ReadFile(path)
+	if err != nil {
+		return fileStamp{}, nil, false, err
+	}
+	stamp.Hash = hashContent(content)
+	if old != nil && old.Hash == stamp.Hash {
+		return stamp, nil, false, nil
+	}
+	return stamp, content, true, nil
+}
+
+// indexKey returns the key of path in an index of the tree under root: its
+// slash-separated path relative to root.
+func indexKey(root string, path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || isOutsideRel(rel) {
+		return filepath.ToSlash(absPath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// staleKeys returns the keys of files under the directory with key prefix
+// that an index update did not see, because they were deleted or are now
+// ignored.
+func staleKeys[E any](files map[string]E, prefix string, seen map[string]bool) []string {
+	var stale []string
+	for key := range files {
+		if seen[key] || !(prefix == "." || key == prefix || strings.HasPrefix(key, prefix+"/")) {
+			continue
+		}
+		stale = append(stale, key)
+	}
+	return stale
+}
+
+// writeIndexFile replaces the file name in the index directory of the state
+// directory for root with data.
+func writeIndexFile(root string, name string, data []byte) error {
+	indexDir, err := makeStateDir(root, "index")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(indexDir, name), data, 0644)
+}
@@ -0,0 +1,189 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAged writes a file with a modification time well before any index
+// save, so that the index trusts its timestamp.
+func writeAged(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symbolNames(t *testing.T, dir string, pattern string) string {
+	t.Helper()
+	results, err := FindSymbols(dir, pattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Match)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestBuildSymbolIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, filepath.Join(tempDir, "main.go"), "package main\n\nfunc Alpha() {}\n", old)
+	writeAged(t, filepath.Join(tempDir, "app.py"), "def beta():\n    pass\n", old)
+	writeAged(t, filepath.Join(tempDir, "README.md"), "# notes\n", old)
+
+	unindexed := symbolNames(t, tempDir, "")
+
+	stats, err := BuildSymbolIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *stats != (IndexStats{Files: 2, Symbols: 2, Parsed: 2}) {
+		t.Errorf("unexpected stats: %+v", *stats)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, stateDirName, "index", symbolIndexName)); err != nil {
+		t.Fatalf("expected the index to be saved: %v", err)
+	}
+	if got := symbolNames(t, tempDir, ""); got != unindexed {
+		t.Errorf("indexed search returned %s, expected %s", got, unindexed)
+	}
+
+	// Nothing is parsed again while files are unchanged
+	stats, err = BuildSymbolIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Parsed != 0 {
+		t.Errorf("expected no files to be parsed, got %+v", *stats)
+	}
+
+	// Deleted files are dropped
+	os.Remove(filepath.Join(tempDir, "app.py"))
+	stats, err = BuildSymbolIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *stats != (IndexStats{Files: 1, Symbols: 1, Removed: 1}) {
+		t.Errorf("unexpected stats after removal: %+v", *stats)
+	}
+}
+
+func TestSymbolIndex_Subdirectory(t *testing.T) {
+	// Outside a repository the index of a directory covers those below it
+	tempDir := t.TempDir()
+	sub := filepath.Join(tempDir, "sub")
+	os.Mkdir(sub, 0755)
+	old := time.Now().Add(-time.Hour)
+	path := filepath.Join(sub, "main.go")
+	writeAged(t, path, "package main\n\nfunc Alpha() {}\n", old)
+
+	if _, err := BuildSymbolIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if openSymbolIndex(sub) == nil {
+		t.Fatal("expected the index to cover the subdirectory")
+	}
+
+	// An edit keeping the size and modification time is not noticed, which
+	// shows the search used the index
+	writeAged(t, path, "package main\n\nfunc Gamma() {}\n", old)
+	if got := symbolNames(t, sub, ""); got != "Alpha" {
+		t.Errorf("expected the indexed symbol, got %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(sub, stateDirName)); !os.IsNotExist(err) {
+		t.Errorf("expected no state directory in the subdirectory, got %v", err)
+	}
+}
+
+func TestSymbolIndex_ReusesUnchangedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, path, "package main\n\nfunc Alpha() {}\n", old)
+
+	if _, err := BuildSymbolIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Same size and modification time: the indexed symbols are trusted
+	writeAged(t, path, "package main\n\nfunc Gamma() {}\n", old)
+	if got := symbolNames(t, tempDir, ""); got != "Alpha" {
+		t.Errorf("expected the indexed symbol, got %s", got)
+	}
+
+	// A changed size is noticed by searches, which update the index
+	writeAged(t, path, "package main\n\nfunc Delta() {}\n\nfunc Epsilon() {}\n", old)
+	if got := symbolNames(t, tempDir, ""); got != "Delta,Epsilon" {
+		t.Errorf("expected the new symbols, got %s", got)
+	}
+	stats, err := BuildSymbolIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Parsed != 0 || stats.Symbols != 2 {
+		t.Errorf("expected the search to have updated the index, got %+v", *stats)
+	}
+}
+
+func TestSymbolIndex_RacyFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	recent := time.Now()
+	writeAged(t, path, "package main\n\nfunc Alpha() {}\n", recent)
+
+	if _, err := BuildSymbolIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Modified too close to the save for its timestamp to be trusted
+	writeAged(t, path, "package main\n\nfunc Gamma() {}\n", recent)
+	if got := symbolNames(t, tempDir, ""); got != "Gamma" {
+		t.Errorf("expected the racy file to be hashed, got %s", got)
+	}
+}
+
+func TestSymbolIndex_OutdatedVersion(t *testing.T) {
+	tempDir := t.TempDir()
+	writeAged(t, filepath.Join(tempDir, "main.go"), "package main\n\nfunc Alpha() {}\n", time.Now().Add(-time.Hour))
+
+	indexDir := filepath.Join(tempDir, stateDirName, "index")
+	os.MkdirAll(indexDir, 0755)
+	os.WriteFile(filepath.Join(indexDir, symbolIndexName), []byte(`{"version":0,"files":{"main.go":{"symbols":[{"Name":"Stale"}]}}}`), 0644)
+
+	if got := symbolNames(t, tempDir, ""); got != "Alpha" {
+		t.Errorf("expected an outdated index to be ignored, got %s", got)
+	}
+	stats, err := BuildSymbolIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Files != 1 || stats.Symbols != 1 {
+		t.Errorf("unexpected stats: %+v", *stats)
+	}
+}
+
+func TestIndexKey(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: filepath.Join(root, "a", "b.go"), want: "a/b.go"},
+		{path: filepath.Join(root, "..foo", "b.go"), want: "..foo/b.go"},
+		{path: filepath.Join(root, "...config"), want: "...config"},
+		{path: filepath.Join(filepath.Dir(root), "other.go"), want: filepath.ToSlash(filepath.Join(filepath.Dir(root), "other.go"))},
+	}
+	for _, tt := range tests {
+		if got := indexKey(root, tt.path); got != tt.want {
+			t.Errorf("indexKey(%s): expected %s, got %s", tt.path, tt.want, got)
+		}
+	}
+}
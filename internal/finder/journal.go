@@ -0,0 +1,238 @@
+package finder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateDirName is the directory vtk keeps its state in, at the repository
+// root or, outside a repository, in the nearest directory from the searched
+// one up that has one, or else the searched directory. Walks never descend
+// into it.
+const stateDirName = ".vtk"
+
+// journalIDFormat names journals so that they sort in the order they ran.
+const journalIDFormat = "20060102T150405.000000000"
+
+// ErrNoJournal is returned when there is no replace run to undo.
+var ErrNoJournal = errors.New("no replace run to undo")
+
+// Journal records a replace run so that it can be undone. It keeps the
+// original content of every file it changed and a hash of the content it
+// wrote, which tells whether a file was modified again afterwards.
+type Journal struct {
+	ID          string        `json:"id"`
+	Time        time.Time     `json:"time"`
+	Description string        `json:"description"`
+	Files       []JournalFile `json:"files"`
+}
+
+// JournalFile is the record of a single file changed by a replace run.
+type JournalFile struct {
+	Path    string `json:"path"` // absolute path
+	Old     []byte `json:"old"`
+	NewHash string `json:"new_hash"`
+}
+
+// ApplyChangesWithJournal applies changes like ApplyChanges after recording
+// them in a journal in the state directory for dir. The description is
+// stored with the journal to help pick a run to undo. If no change is made
+// no journal is written and nil is returned.
+func ApplyChangesWithJournal(dir string, description string, changes []Change) (*Journal, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	journal := &Journal{Time: time.Now(), Description: description}
+	journal.ID = journal.Time.UTC().Format(journalIDFormat)
+	for _, change := range changes {
+		path, err := filepath.Abs(change.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", change.Path, err)
+		}
+		journal.Files = append(journal.Files, JournalFile{
+			Path:    path,
+			Old:     change.Old,
+			NewHash: hashContent(change.New),
+		})
+	}
+
+	// The journal is written first so that a run interrupted while applying
+	// can still be undone
+	path, err := writeJournal(dir, journal)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyChanges(changes); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return journal, nil
+}
+
+// ListJournals returns the replace runs recorded for dir, oldest first.
+func ListJournals(dir string) ([]*Journal, error) {
+	journalDir := journalDir(dir)
+	entries, err := os.ReadDir(journalDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		journal, err := readJournal(filepath.Join(journalDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		journals = append(journals, journal)
+	}
+
+	sort.Slice(journals, func(i, j int) bool {
+		return journals[i].ID < journals[j].ID
+	})
+	return journals, nil
+}
+
+// Undo restores the files changed by the replace run with the given ID, or
+// by the most recent run when id is empty, and deletes its journal. It
+// refuses to touch anything if any of the files was modified since the run.
+func Undo(dir string, id string) (*Journal, error) {
+	if id == "" {
+		journals, err := ListJournals(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(journals) == 0 {
+			return nil, ErrNoJournal
+		}
+		id = journals[len(journals)-1].ID
+	} else if filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid run name: %s", id)
+	}
+	path := filepath.Join(journalDir(dir), id+".json")
+
+	journal, err := readJournal(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no replace run named %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, file := range journal.Files {
+		current, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if hashContent(current) != file.NewHash {
+			return nil, fmt.Errorf("%s was modified after run %s", file.Path, journal.ID)
+		}
+		changes = append(changes, Change{Path: file.Path, Old: current, New: file.Old})
+	}
+
+	if err := ApplyChanges(changes); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove journal %s: %w", journal.ID, err)
+	}
+	return journal, nil
+}
+
+// stateRoot returns the directory whose state directory holds the state
+// for dir: the root of the repository containing dir or, outside a
+// repository, the nearest directory from dir up that already has a state
+// directory, or dir itself.
+func stateRoot(dir string) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		absDir = dir
+	}
+	root, gitDir := findRepoRoot(absDir)
+	if gitDir != "" {
+		return root
+	}
+	for d := absDir; ; d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(d, stateDirName)); err == nil && info.IsDir() {
+			return d
+		}
+		if d == filepath.Dir(d) {
+			return absDir
+		}
+	}
+}
+
+// makeStateDir creates the directory sub of the state directory for dir and
+// returns its path.
+func makeStateDir(dir string, sub string) (string, error) {
+	stateDir := filepath.Join(stateRoot(dir), stateDirName)
+	path := filepath.Join(stateDir, sub)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	// Keep the state directory out of git without touching .gitignore
+	gitignore := filepath.Join(stateDir, ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		os.WriteFile(gitignore, []byte("*\n"), 0644)
+	}
+	return path, nil
+}
+
+// journalDir returns the directory holding the journals for dir.
+func journalDir(dir string) string {
+	return filepath.Join(stateRoot(dir), stateDirName, "journal")
+}
+
+// writeJournal saves journal in the state directory for dir and returns the
+// path it was written to.
+func writeJournal(dir string, journal *Journal) (string, error) {
+	journalDir, err := makeStateDir(dir, "journal")
+	if err != nil {
+		return "", fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode journal: %w", err)
+	}
+	path := filepath.Join(journalDir, journal.ID+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write journal: %w", err)
+	}
+	return path, nil
+}
+
+// readJournal loads the journal stored at path.
+func readJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	var journal Journal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("failed to decode journal %s: %w", filepath.Base(path), err)
+	}
+	return &journal, nil
+}
+
+// hashContent returns the hex SHA-256 of content.
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
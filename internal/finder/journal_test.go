@@ -0,0 +1,124 @@
+package finder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUndo_RestoresLastRun(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"a.txt":     "hello world\n",
+		"sub/b.txt": "say hello\n",
+	})
+
+	if _, err := Replace(tempDir, "hello", "goodbye"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Replace(tempDir, "world", "moon"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	journals, err := ListJournals(tempDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(journals) != 2 {
+		t.Fatalf("expected 2 journals, got %d", len(journals))
+	}
+	if journals[1].Description != `replace "world" with "moon"` {
+		t.Errorf("unexpected description %q", journals[1].Description)
+	}
+
+	// The journal itself is never searched or replaced in
+	results, err := Find(tempDir, "hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results, found %v", results)
+	}
+
+	// Undo steps back one run at a time
+	if _, err := Undo(tempDir, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(tempDir, "a.txt"))
+	if string(content) != "goodbye world\n" {
+		t.Errorf("unexpected content after first undo %q", content)
+	}
+
+	if _, err := Undo(tempDir, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(tempDir, "sub", "b.txt"))
+	if string(content) != "say hello\n" {
+		t.Errorf("unexpected content after second undo %q", content)
+	}
+
+	if _, err := Undo(tempDir, ""); !errors.Is(err, ErrNoJournal) {
+		t.Errorf("expected ErrNoJournal, got %v", err)
+	}
+}
+
+func TestUndo_NamedRun(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"a.txt": "alpha\n",
+		"b.txt": "beta\n",
+	})
+	a, b := filepath.Join(tempDir, "a.txt"), filepath.Join(tempDir, "b.txt")
+
+	first, err := ApplyChangesWithJournal(tempDir, "first", []Change{{Path: a, Old: []byte("alpha\n"), New: []byte("ALPHA\n")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ApplyChangesWithJournal(tempDir, "second", []Change{{Path: b, Old: []byte("beta\n"), New: []byte("BETA\n")}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Undo(tempDir, first.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(a); string(content) != "alpha\n" {
+		t.Errorf("expected a.txt to be restored, got %q", content)
+	}
+	if content, _ := os.ReadFile(b); string(content) != "BETA\n" {
+		t.Errorf("expected b.txt to keep the second run, got %q", content)
+	}
+
+	if _, err := Undo(tempDir, first.ID); err == nil || !strings.Contains(err.Error(), "no replace run named") {
+		t.Errorf("expected unknown run error, got %v", err)
+	}
+	if _, err := Undo(tempDir, "../escape"); err == nil {
+		t.Error("expected an error for a run name with a path")
+	}
+}
+
+func TestUndo_RefusesModifiedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"a.txt": "hello\n",
+		"b.txt": "hello\n",
+	})
+
+	if _, err := Replace(tempDir, "hello", "goodbye"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	os.WriteFile(filepath.Join(tempDir, "b.txt"), []byte("edited by hand\n"), 0644)
+
+	if _, err := Undo(tempDir, ""); err == nil || !strings.Contains(err.Error(), "modified after run") {
+		t.Fatalf("expected modification error, got %v", err)
+	}
+
+	// Nothing was restored and the run can still be undone later
+	if content, _ := os.ReadFile(filepath.Join(tempDir, "a.txt")); string(content) != "goodbye\n" {
+		t.Errorf("expected a.txt to be untouched, got %q", content)
+	}
+	if journals, _ := ListJournals(tempDir); len(journals) != 1 {
+		t.Errorf("expected the journal to be kept, found %d", len(journals))
+	}
+}
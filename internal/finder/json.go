@@ -0,0 +1,246 @@
+package finder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonText is ripgrep's encoding of a path or line of text.
+type jsonText struct {
+	Text string `json:"text"`
+}
+
+// jsonSubmatch locates one match within the text of a match event.
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+// jsonSymbol carries the symbol details of a symbol search match. It is an
+// extension to ripgrep's schema that ripgrep consumers can ignore.
+type jsonSymbol struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind,omitempty"`
+	Column int    `json:"column"`
+}
+
+// jsonLine is the data of a match or context event.
+type jsonLine struct {
+	Path       jsonText       `json:"path"`
+	Lines      jsonText       `json:"lines"`
+	LineNumber *int           `json:"line_number"`
+	Submatches []jsonSubmatch `json:"submatches"`
+	Symbol     *jsonSymbol    `json:"symbol,omitempty"`
+}
+
+// jsonElapsed is ripgrep's encoding of a duration.
+type jsonElapsed struct {
+	Secs  int64  `json:"secs"`
+	Nanos int    `json:"nanos"`
+	Human string `json:"human"`
+}
+
+// jsonStats summarizes a file or a whole search.
+type jsonStats struct {
+	Elapsed           *jsonElapsed `json:"elapsed,omitempty"`
+	SearchesWithMatch int          `json:"searches_with_match"`
+	MatchedLines      int          `json:"matched_lines"`
+	Matches           int          `json:"matches"`
+}
+
+// jsonEvent is a single line of JSON output.
+type jsonEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// JSONWriter writes results as JSON Lines using ripgrep's --json event
+// schema: a begin event when a file's results start, match and context
+// events for its lines, an end event when the file is done and a final
+// summary event. Results must arrive grouped by file, as the finder
+// produces them.
+type JSONWriter struct {
+	w     io.Writer
+	start time.Time
+
+	path  string   // file currently open with a begin event
+	line  []Result // results on the line waiting to be written
+	file  jsonStats
+	total jsonStats
+	err   error
+}
+
+// NewJSONWriter returns a JSONWriter writing to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w, start: time.Now()}
+}
+
+// Write adds a text search result. Results on the same line are combined
+// into one match event with several submatches.
+func (j *JSONWriter) Write(result Result) error {
+	if len(j.line) > 0 && (j.line[0].Path != result.Path || j.line[0].Line != result.Line) {
+		j.flushLine()
+	}
+	j.beginFile(result.Path)
+	j.line = append(j.line, result)
+	return j.err
+}
+
+// WriteSymbol adds a symbol search result as a match event whose text is
+// the symbol name.
+func (j *JSONWriter) WriteSymbol(result Result) error {
+	j.flushLine()
+	j.beginFile(result.Path)
+	j.file.MatchedLines++
+	j.file.Matches++
+
+	line := result.Line
+	j.emit("match", jsonLine{
+		Path:       jsonText{result.Path},
+		Lines:      jsonText{result.Match + "\n"},
+		LineNumber: &line,
+		Submatches: []jsonSubmatch{{Match: jsonText{result.Match}, Start: 0, End: len(result.Match)}},
+		Symbol:     &jsonSymbol{Name: result.Match, Kind: result.Kind, Column: result.Column},
+	})
+	return j.err
+}
+
+// WritePath adds a glob result as a match event naming only the path.
+func (j *JSONWriter) WritePath(result Result) error {
+	j.flushLine()
+	j.endFile()
+	j.total.SearchesWithMatch++
+	j.total.Matches++
+
+	j.emit("match", jsonLine{
+		Path:       jsonText{result.Path},
+		Lines:      jsonText{result.Match + "\n"},
+		Submatches: []jsonSubmatch{},
+	})
+	return j.err
+}
+
+// Close ends the last file and writes the summary event.
+func (j *JSONWriter) Close() error {
+	j.flushLine()
+	j.endFile()
+
+	elapsed := newJSONElapsed(time.Since(j.start))
+	j.total.Elapsed = &elapsed
+	j.emit("summary", struct {
+		ElapsedTotal jsonElapsed `json:"elapsed_total"`
+		Stats        jsonStats   `json:"stats"`
+	}{elapsed, j.total})
+	return j.err
+}
+
+// beginFile emits a begin event when path differs from the open file.
+func (j *JSONWriter) beginFile(path string) {
+	if j.path == path {
+		return
+	}
+	j.endFile()
+	j.path = path
+	j.emit("begin", struct {
+		Path jsonText `json:"path"`
+	}{jsonText{path}})
+}
+
+// endFile emits the end event for the open file, if any.
+func (j *JSONWriter) endFile() {
+	if j.path == "" {
+		return
+	}
+	j.file.SearchesWithMatch = 1
+	j.emit("end", struct {
+		Path         jsonText  `json:"path"`
+		BinaryOffset *int      `json:"binary_offset"`
+		Stats        jsonStats `json:"stats"`
+	}{Path: jsonText{j.path}, Stats: j.file})
+
+	j.total.SearchesWithMatch++
+	j.total.MatchedLines += j.file.MatchedLines
+	j.total.Matches += j.file.Matches
+	j.path = ""
+	j.file = jsonStats{}
+}
+
+// flushLine writes the buffered results of one line with their context.
+func (j *JSONWriter) flushLine() {
+	if len(j.line) == 0 {
+		return
+	}
+	first, last := j.line[0], j.line[len(j.line)-1]
+
+	for _, ctx := range first.Before {
+		j.emitContext(first.Path, ctx)
+	}
+
+	// Multiline matches only carry their first line, so the text of the
+	// event runs from the start of that line to the end of the match.
+	text := first.Match
+	var submatches []jsonSubmatch
+	for _, r := range j.line {
+		end := r.EndColumn
+		if r.EndLine > r.Line {
+			text = r.Match[:r.Column] + r.Matched
+			end = len(text)
+		}
+		submatches = append(submatches, jsonSubmatch{
+			Match: jsonText{r.Matched},
+			Start: r.Column,
+			End:   end,
+		})
+	}
+
+	line := first.Line
+	j.emit("match", jsonLine{
+		Path:       jsonText{first.Path},
+		Lines:      jsonText{text + "\n"},
+		LineNumber: &line,
+		Submatches: submatches,
+	})
+	j.file.MatchedLines += max(last.EndLine, last.Line) - first.Line + 1
+	j.file.Matches += len(j.line)
+
+	for _, ctx := range last.After {
+		j.emitContext(last.Path, ctx)
+	}
+	j.line = j.line[:0]
+}
+
+// emitContext writes a context event.
+func (j *JSONWriter) emitContext(path string, ctx ContextLine) {
+	line := ctx.Line
+	j.emit("context", jsonLine{
+		Path:       jsonText{path},
+		Lines:      jsonText{ctx.Text + "\n"},
+		LineNumber: &line,
+		Submatches: []jsonSubmatch{},
+	})
+}
+
+// emit writes one event, remembering the first write error.
+func (j *JSONWriter) emit(eventType string, data any) {
+	if j.err != nil {
+		return
+	}
+	encoded, err := json.Marshal(jsonEvent{Type: eventType, Data: data})
+	if err != nil {
+		j.err = fmt.Errorf("failed to encode %s event: %w", eventType, err)
+		return
+	}
+	_, j.err = j.w.Write(append(encoded, '\n'))
+}
+
+// newJSONElapsed converts a duration to ripgrep's elapsed encoding.
+func newJSONElapsed(d time.Duration) jsonElapsed {
+	return jsonElapsed{
+		Secs:  int64(d / time.Second),
+		Nanos: int(d % time.Second),
+		Human: fmt.Sprintf("%.6fs", d.Seconds()),
+	}
+}
@@ -0,0 +1,110 @@
+package finder
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// decodeEvents parses JSON Lines output into generic events.
+func decodeEvents(t *testing.T, output string) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func eventTypes(events []map[string]any) []string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e["type"].(string))
+	}
+	return types
+}
+
+func TestJSONWriter_TextResults(t *testing.T) {
+	results := []Result{
+		{Path: "a.txt", Line: 2, Column: 0, Match: "foo bar foo", EndLine: 2, EndColumn: 3, Matched: "foo",
+			Before: []ContextLine{{Line: 1, Text: "intro"}}},
+		{Path: "a.txt", Line: 2, Column: 8, Match: "foo bar foo", EndLine: 2, EndColumn: 11, Matched: "foo",
+			After: []ContextLine{{Line: 3, Text: "outro"}}},
+		{Path: "b.txt", Line: 5, Column: 4, Match: "the foo", EndLine: 5, EndColumn: 7, Matched: "foo"},
+	}
+
+	var output strings.Builder
+	w := NewJSONWriter(&output)
+	for _, r := range results {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := decodeEvents(t, output.String())
+	want := []string{"begin", "context", "match", "context", "end", "begin", "match", "end", "summary"}
+	if got := eventTypes(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+
+	// Both matches on line 2 share a single match event
+	match := events[2]["data"].(map[string]any)
+	if match["lines"].(map[string]any)["text"] != "foo bar foo\n" {
+		t.Errorf("unexpected match text: %v", match["lines"])
+	}
+	if match["line_number"].(float64) != 2 {
+		t.Errorf("unexpected line number: %v", match["line_number"])
+	}
+	submatches := match["submatches"].([]any)
+	if len(submatches) != 2 {
+		t.Fatalf("expected 2 submatches, got %d", len(submatches))
+	}
+	second := submatches[1].(map[string]any)
+	if second["start"].(float64) != 8 || second["end"].(float64) != 11 {
+		t.Errorf("unexpected submatch span: %v", second)
+	}
+
+	summary := events[len(events)-1]["data"].(map[string]any)
+	stats := summary["stats"].(map[string]any)
+	if stats["matches"].(float64) != 3 || stats["matched_lines"].(float64) != 2 || stats["searches_with_match"].(float64) != 2 {
+		t.Errorf("unexpected summary stats: %v", stats)
+	}
+}
+
+func TestJSONWriter_SymbolAndPathResults(t *testing.T) {
+	var output strings.Builder
+	w := NewJSONWriter(&output)
+	w.WriteSymbol(Result{Path: "main.go", Line: 3, Column: 5, Match: "HelloWorld", Kind: "func"})
+	w.Close()
+
+	events := decodeEvents(t, output.String())
+	if got := strings.Join(eventTypes(events), ","); got != "begin,match,end,summary" {
+		t.Fatalf("unexpected events: %s", got)
+	}
+	symbol := events[1]["data"].(map[string]any)["symbol"].(map[string]any)
+	if symbol["name"] != "HelloWorld" || symbol["kind"] != "func" || symbol["column"].(float64) != 5 {
+		t.Errorf("unexpected symbol data: %v", symbol)
+	}
+
+	output.Reset()
+	w = NewJSONWriter(&output)
+	w.WritePath(Result{Path: "dir/file.go", Match: "file.go"})
+	w.WritePath(Result{Path: "dir/other.go", Match: "other.go"})
+	w.Close()
+
+	events = decodeEvents(t, output.String())
+	if got := strings.Join(eventTypes(events), ","); got != "match,match,summary" {
+		t.Fatalf("unexpected events: %s", got)
+	}
+	data := events[0]["data"].(map[string]any)
+	if data["path"].(map[string]any)["text"] != "dir/file.go" || data["line_number"] != nil {
+		t.Errorf("unexpected path event: %v", data)
+	}
+}
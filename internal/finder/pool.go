@@ -0,0 +1,166 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// errMaxCount stops a stream once Options.MaxCount results were delivered.
+var errMaxCount = errors.New("maximum result count reached")
+
+// fileJob is a file queued for searching. Its results are delivered on a
+// buffered channel so that they can be consumed in walk order.
+type fileJob struct {
+	path    string
+	results chan []Result
+}
+
+// streamFiles searches the files produced by walk on a bounded pool of
+// workers and passes each result to emit. Walking and searching overlap, but
+// results are emitted in the order walk visited the files, then by line and
+// column within a file. Files for which search returns an error are skipped.
+//
+// Streaming stops when ctx is cancelled, when emit returns an error or once
+// opts.MaxCount results were emitted. Only the first and last of these are
+// reported as errors.
+func streamFiles(ctx context.Context, opts Options, walk func(visit func(path string) error) error,
+	search func(path string) ([]Result, error), emit func(Result) error) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan fileJob)
+	// pending queues jobs in walk order and bounds how far the walk can run
+	// ahead of the consumer.
+	pending := make(chan fileJob, workers*4)
+
+	var walkErr error
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		walkErr = walk(func(path string) error {
+			job := fileJob{path: path, results: make(chan []Result, 1)}
+			select {
+			case pending <- job:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				job.results <- nil
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var matches []Result
+				if ctx.Err() == nil {
+					if found, err := search(job.path); err == nil {
+						sortByPosition(found)
+						matches = found
+					}
+				}
+				job.results <- matches
+			}
+		}()
+	}
+
+	emitted := 0
+	var emitErr error
+	for job := range pending {
+		matches := <-job.results
+		if emitErr != nil {
+			continue // Drain so the walker and workers can exit
+		}
+		for _, match := range matches {
+			if opts.MaxCount > 0 && emitted >= opts.MaxCount {
+				emitErr = errMaxCount
+				break
+			}
+			if emitErr = emit(match); emitErr != nil {
+				break
+			}
+			emitted++
+		}
+		if emitErr == nil && opts.MaxCount > 0 && emitted >= opts.MaxCount {
+			emitErr = errMaxCount
+		}
+		if emitErr != nil {
+			cancel()
+		}
+	}
+	wg.Wait()
+
+	switch {
+	case errors.Is(emitErr, errMaxCount):
+		return nil
+	case emitErr != nil:
+		return emitErr
+	case walkErr != nil && !errors.Is(walkErr, context.Canceled):
+		return walkErr
+	}
+	return ctx.Err()
+}
+
+// walkStream passes each path produced by walk to emit as a result built by
+// toResult, honoring cancellation and opts.MaxCount like streamFiles. It is
+// used by walkers that only look at names and need no worker pool.
+func walkStream(ctx context.Context, opts Options, walk func(visit func(path string) error) error,
+	toResult func(path string) Result, emit func(Result) error) error {
+	emitted := 0
+	err := walk(func(path string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emit(toResult(path)); err != nil {
+			return err
+		}
+		emitted++
+		if opts.MaxCount > 0 && emitted >= opts.MaxCount {
+			return errMaxCount
+		}
+		return nil
+	})
+	if errors.Is(err, errMaxCount) {
+		return nil
+	}
+	return err
+}
+
+// collect runs a streaming search and gathers its results into a slice.
+func collect(stream func(emit func(Result) error) error) ([]Result, error) {
+	var results []Result
+	err := stream(func(r Result) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// sortByPosition orders results from a single file by line, then column.
+func sortByPosition(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Line != results[j].Line {
+			return results[i].Line < results[j].Line
+		}
+		return results[i].Column < results[j].Column
+	})
+}
@@ -0,0 +1,131 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeNeedles creates count files, each with three matching lines.
+func writeNeedles(t *testing.T, dir string, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%02d", i%5))
+		os.MkdirAll(sub, 0755)
+		content := "needle one\nhay\nneedle two\nneedle three"
+		os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%02d.txt", i)), []byte(content), 0644)
+	}
+}
+
+func TestFindStream_MatchesBatch(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNeedles(t, tempDir, 40)
+
+	batch, err := FindWithOptions(tempDir, "needle", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var streamed []Result
+	err = FindStream(context.Background(), tempDir, "needle", Options{Workers: 3}, func(r Result) error {
+		streamed = append(streamed, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if FormatEmacsOutput(streamed) != FormatEmacsOutput(batch) {
+		t.Error("streamed results differ from batch results")
+	}
+}
+
+func TestFindStream_MaxCount(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNeedles(t, tempDir, 20)
+
+	batch, _ := FindWithOptions(tempDir, "needle", Options{})
+
+	var streamed []Result
+	err := FindStream(context.Background(), tempDir, "needle", Options{MaxCount: 5}, func(r Result) error {
+		streamed = append(streamed, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error when the limit is reached, got %v", err)
+	}
+	if len(streamed) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(streamed))
+	}
+	if FormatEmacsOutput(streamed) != FormatEmacsOutput(batch[:5]) {
+		t.Error("expected the first 5 results in order")
+	}
+
+	// Glob streams honor the limit too
+	files, err := collect(func(emit func(Result) error) error {
+		return GlobFilesStream(context.Background(), tempDir, `\.txt$`, Options{MaxCount: 3}, emit)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files, got %d", len(files))
+	}
+}
+
+func TestFindStream_Cancel(t *testing.T) {
+	tempDir := t.TempDir()
+	writeNeedles(t, tempDir, 50)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	err := FindStream(ctx, tempDir, "needle", Options{Workers: 2}, func(r Result) error {
+		count++
+		if count == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if count >= 150 {
+		t.Errorf("expected the search to stop early, got all %d results", count)
+	}
+
+	// An already cancelled context produces no results
+	count = 0
+	err = GlobDirectoriesStream(ctx, tempDir, ".*", Options{}, func(r Result) error {
+		count++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || count != 0 {
+		t.Errorf("expected cancellation before any result, got %d results and %v", count, err)
+	}
+}
+
+func TestFindSymbolsStream_CallbackError(t *testing.T) {
+	tempDir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		content := fmt.Sprintf("package main\n\nfunc Handler%d() {}\n", i)
+		os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("h%d.go", i)), []byte(content), 0644)
+	}
+
+	stopErr := errors.New("stop")
+	count := 0
+	err := FindSymbolsStream(context.Background(), tempDir, "Handler", Options{}, func(r Result) error {
+		count++
+		return stopErr
+	})
+	if !errors.Is(err, stopErr) {
+		t.Fatalf("expected callback error to be returned, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected a single callback, got %d", count)
+	}
+}
@@ -0,0 +1,274 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// identOccurrence is an identifier in code, outside comments and strings,
+// with its 1-based line and 0-based byte column.
+type identOccurrence struct {
+	name   string
+	line   int
+	column int
+}
+
+// FindRefs finds the occurrences of the identifier name in code files under
+// dir, skipping comments and strings. Each result has Kind "definition"
+// where the occurrence is the name of a symbol found by FindSymbols, and
+// "reference" otherwise. Match holds the text of the line and Matched the
+// identifier.
+//
+// A qualified name such as "Client.fetch" matches the occurrences of fetch,
+// but only the definitions whose qualified name is "Client.fetch". Since
+// references are not resolved, uses of other symbols with the same name are
+// reported as well.
+func FindRefs(dir string, name string) ([]Result, error) {
+	return FindRefsWithOptions(dir, name, Options{})
+}
+
+// FindRefsWithOptions is like FindRefs but scans files on a pool of
+// opts.Workers goroutines. Results come in walk order, with the entries of
+// each directory in lexical order, then by line, then by column.
+func FindRefsWithOptions(dir string, name string, opts Options) ([]Result, error) {
+	return collect(func(emit func(Result) error) error {
+		return FindRefsStream(context.Background(), dir, name, opts, emit)
+	})
+}
+
+// FindRefsStream is like FindRefsWithOptions but passes each result to fn
+// as soon as it is available. It stops early under the same conditions as
+// FindStream.
+func FindRefsStream(ctx context.Context, dir string, name string, opts Options, fn func(Result) error) error {
+	bare := name[strings.LastIndex(name, ".")+1:]
+	if bare == "" {
+		return fmt.Errorf("invalid symbol name: %q", name)
+	}
+
+	// Check if directory exists
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory does not exist: %s", dir)
+	}
+
+	filter, err := newFileFilter(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	// Reuse the indexes when the tree was indexed
+	extract := extractSymbols
+	if index := opts.Cache.symbolIndex(dir); index != nil {
+		extract = index.symbols
+		defer index.save()
+	}
+	mayMatch := func(path string) bool { return true }
+	if index := opts.Cache.textIndex(dir); index != nil {
+		query := regexpTrigramQuery(regexp.QuoteMeta(bare))
+		mayMatch = func(path string) bool { return index.mayMatch(path, query) }
+		defer index.save()
+	}
+
+	return streamFiles(ctx, opts, walkSymbolFiles(dir, opts.Cache, filter), func(path string) ([]Result, error) {
+		if !mayMatch(path) {
+			return nil, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		symbols, err := extract(path)
+		if err != nil {
+			return nil, err
+		}
+		return fileRefs(path, content, symbols, name), nil
+	}, fn)
+}
+
+// fileRefs returns the occurrences of the identifier name in the content of
+// the code file at path, given the file's symbols.
+func fileRefs(path string, content []byte, symbols []Symbol, name string) []Result {
+	bare := name[strings.LastIndex(name, ".")+1:]
+	qualified := bare != name
+
+	// SQL names are case-insensitive
+	same := func(a string, b string) bool { return a == b }
+	if filepath.Ext(path) == ".sql" {
+		same = strings.EqualFold
+	}
+
+	// Positions of the definitions of name, and of other symbols sharing
+	// its bare name, which cannot be references to it
+	type position struct{ line, column int }
+	definitions := make(map[position]bool)
+	others := make(map[position]bool)
+	for _, symbol := range symbols {
+		if !same(symbol.Name, bare) {
+			continue
+		}
+		pos := position{symbol.Line, symbol.Column}
+		if !qualified || same(symbol.QualifiedName(), name) {
+			definitions[pos] = true
+		} else {
+			others[pos] = true
+		}
+	}
+
+	lines := strings.Split(string(content), "\n")
+	result := func(line int, column int, text string, kind string) Result {
+		lineText := ""
+		if line-1 < len(lines) {
+			lineText = strings.TrimSuffix(lines[line-1], "\r")
+		}
+		return Result{
+			Path:      path,
+			Line:      line,
+			Column:    column,
+			Match:     lineText,
+			EndLine:   line,
+			EndColumn: column + len(text),
+			Matched:   text,
+			Kind:      kind,
+		}
+	}
+
+	var results []Result
+	found := make(map[position]bool)
+	for _, ident := range codeIdentifiers(path, content) {
+		pos := position{ident.line, ident.column}
+		if !same(ident.name, bare) || others[pos] {
+			continue
+		}
+		kind := "reference"
+		if definitions[pos] {
+			kind = "definition"
+		}
+		found[pos] = true
+		results = append(results, result(ident.line, ident.column, ident.name, kind))
+	}
+
+	// Definitions the scan cannot see, such as quoted member names
+	for _, symbol := range symbols {
+		pos := position{symbol.Line, symbol.Column}
+		if definitions[pos] && !found[pos] {
+			found[pos] = true
+			results = append(results, result(symbol.Line, symbol.Column, symbol.Name, "definition"))
+		}
+	}
+	return results
+}
+
+// codeIdentifiers returns the identifiers in the content of the code file
+// at path, leaving out comments and strings.
+func codeIdentifiers(path string, content []byte) []identOccurrence {
+	switch ext := filepath.Ext(path); ext {
+	case ".go":
+		return goIdentifiers(content)
+	case ".ts", ".tsx", ".js", ".jsx":
+		return jsIdentifiers(content, ext != ".ts")
+	case ".py":
+		return pythonIdentifiers(content)
+	case ".sql":
+		return sqlIdentifiers(content)
+	default:
+		return nil
+	}
+}
+
+// goIdentifiers returns the identifiers of Go source.
+func goIdentifiers(content []byte) []identOccurrence {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(content))
+	var s scanner.Scanner
+	s.Init(file, content, nil, 0)
+
+	var idents []identOccurrence
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return idents
+		}
+		if tok == token.IDENT {
+			position := fset.Position(pos)
+			idents = append(idents, identOccurrence{name: lit, line: position.Line, column: position.Column - 1})
+		}
+	}
+}
+
+// jsIdentifiers returns the identifiers of JavaScript or TypeScript source,
+// including those in JSX tag names and expressions.
+func jsIdentifiers(content []byte, jsx bool) []identOccurrence {
+	lexer := newJSLexer(content, jsx)
+	var idents []identOccurrence
+	for _, tok := range append(lexer.tokens(), lexer.jsxIdents...) {
+		if tok.kind == jsIdent {
+			idents = append(idents, identOccurrence{name: tok.text, line: tok.line, column: tok.column})
+		}
+	}
+	return idents
+}
+
+// pythonStringPrefixes are the prefixes of Python string literals, which
+// are not identifiers.
+var pythonStringPrefixes = map[string]bool{
+	"r": true, "u": true, "b": true, "f": true, "rb": true, "br": true, "fr": true, "rf": true,
+}
+
+// pythonIdentifiers returns the identifiers of Python source.
+func pythonIdentifiers(content []byte) []identOccurrence {
+	masked, _ := maskPythonStrings(content)
+
+	var idents []identOccurrence
+	line, lineStart := 1, 0
+	for i := 0; i < len(masked); {
+		c := masked[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = i + 1
+			i++
+		case isDigit(c):
+			// Numbers such as 1e10 or 0x1f are not identifiers
+			for i < len(masked) && isPythonIdentByte(masked[i]) {
+				i++
+			}
+		case isPythonIdentByte(c):
+			start := i
+			for i < len(masked) && isPythonIdentByte(masked[i]) {
+				i++
+			}
+			name := string(masked[start:i])
+			if i < len(masked) && (masked[i] == '\'' || masked[i] == '"') && pythonStringPrefixes[strings.ToLower(name)] {
+				continue
+			}
+			idents = append(idents, identOccurrence{name: name, line: line, column: start - lineStart})
+		default:
+			i++
+		}
+	}
+	return idents
+}
+
+func isPythonIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c >= 0x80
+}
+
+// sqlIdentifiers returns the words and quoted identifiers of SQL source. The
+// column of a quoted identifier is that of its first character.
+func sqlIdentifiers(content []byte) []identOccurrence {
+	var idents []identOccurrence
+	for _, tok := range tokenizeSQL(content) {
+		switch {
+		case tok.kind == sqlQuoted:
+			idents = append(idents, identOccurrence{name: tok.text, line: tok.line, column: tok.column + 1})
+		case tok.kind == sqlWord && !isDigit(tok.text[0]):
+			idents = append(idents, identOccurrence{name: tok.text, line: tok.line, column: tok.column})
+		}
+	}
+	return idents
+}
@@ -0,0 +1,148 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// refSummary is the part of a reference result the tests check.
+type refSummary struct {
+	Line   int
+	Column int
+	Kind   string
+}
+
+func TestFindRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		symbol  string
+		want    []refSummary
+	}{
+		{
+			name: "go skips comments and strings",
+			file: "main.go",
+			content: `package main
+
+// Greet is called below; Greet in a comment is ignored
+func Greet() string { return "Greet" }
+
+func main() {
+	Greet()
+	_ = ` + "`Greet`" + `
+}
+`,
+			symbol: "Greet",
+			want:   []refSummary{{4, 5, "definition"}, {7, 1, "reference"}},
+		},
+		{
+			name: "go qualified method",
+			file: "server.go",
+			content: `package main
+
+type Server struct{}
+type Client struct{}
+
+func (s *Server) Close() {}
+func (c *Client) Close() {}
+
+func stop(s *Server) { s.Close() }
+`,
+			symbol: "Server.Close",
+			want:   []refSummary{{6, 17, "definition"}, {9, 25, "reference"}},
+		},
+		{
+			name: "typescript with jsx and templates",
+			file: "app.jsx",
+			content: `// Button renders a button
+export function Button() { return null; }
+
+const label = "Button";
+const title = ` + "`${Button.name} Button`" + `;
+const view = <Panel><Button onClick={() => Button()} /></Panel>;
+`,
+			symbol: "Button",
+			want: []refSummary{
+				{2, 16, "definition"},
+				{5, 17, "reference"},
+				{6, 21, "reference"},
+				{6, 43, "reference"},
+			},
+		},
+		{
+			name: "python skips strings and prefixes",
+			file: "client.py",
+			content: `class Client:
+    """Client docstring"""
+
+    def fetch(self):
+        return f"fetch {self.fetch}"
+
+def fetch():
+    # fetch again
+    return Client().fetch()
+`,
+			symbol: "Client.fetch",
+			want:   []refSummary{{4, 8, "definition"}, {9, 20, "reference"}},
+		},
+		{
+			name: "sql is case-insensitive",
+			file: "schema.sql",
+			content: `CREATE TABLE users (id int);
+-- users comment
+INSERT INTO Users VALUES (1);
+SELECT 'users' FROM "users";
+`,
+			symbol: "users",
+			want: []refSummary{
+				{1, 13, "definition"},
+				{3, 12, "reference"},
+				{4, 21, "reference"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			os.WriteFile(filepath.Join(tempDir, tt.file), []byte(tt.content), 0644)
+
+			results, err := FindRefs(tempDir, tt.symbol)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []refSummary
+			for _, result := range results {
+				got = append(got, refSummary{result.Line, result.Column, result.Kind})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFindRefs_Output(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	os.WriteFile(path, []byte("package main\n\nfunc run() {}\n\nfunc main() { run() }\n"), 0644)
+
+	results, err := FindRefs(tempDir, "run")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := path + ":3:5: definition func run() {}\n" + path + ":5:14: reference func main() { run() }\n"
+	if output := FormatEmacsOutput(results); output != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, output)
+	}
+	if results[1].Matched != "run" || results[1].EndColumn != 17 {
+		t.Errorf("unexpected match bounds: %+v", results[1])
+	}
+
+	if _, err := FindRefs(tempDir, "Server."); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+}
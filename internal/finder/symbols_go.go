@@ -0,0 +1,127 @@
+package finder
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// extractGoSymbols extracts the top-level declarations of a Go file, along
+// with struct fields and interface methods, using go/parser. Kinds are
+// "func", "method", "struct", "interface", "alias", "type" (for other
+// defined types), "const", "var" and "field". Methods and fields carry the
+// name of the type they belong to as their receiver.
+func extractGoSymbols(content []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	// A file with syntax errors still yields the declarations parsed so far
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+
+	var symbols []Symbol
+	add := func(ident *ast.Ident, kind string, receiver string) {
+		if ident == nil || ident.Name == "_" {
+			return
+		}
+		pos := fset.Position(ident.Pos())
+		symbols = append(symbols, Symbol{
+			Name:     ident.Name,
+			Line:     pos.Line,
+			Column:   pos.Column - 1,
+			Kind:     kind,
+			Receiver: receiver,
+			Exported: ast.IsExported(ident.Name),
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				add(decl.Name, "method", goTypeName(decl.Recv.List[0].Type))
+			} else {
+				add(decl.Name, "func", "")
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					addGoTypeSpec(spec, add)
+				case *ast.ValueSpec:
+					kind := "var"
+					if decl.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, name := range spec.Names {
+						add(name, kind, "")
+					}
+				}
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// addGoTypeSpec adds a type declaration and, for structs and interfaces,
+// its fields and methods.
+func addGoTypeSpec(spec *ast.TypeSpec, add func(*ast.Ident, string, string)) {
+	if spec.Assign.IsValid() {
+		add(spec.Name, "alias", "")
+		return
+	}
+
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		add(spec.Name, "struct", "")
+		for _, field := range t.Fields.List {
+			if len(field.Names) == 0 {
+				// Embedded fields are named after their type
+				add(goTypeIdent(field.Type), "field", spec.Name.Name)
+			}
+			for _, name := range field.Names {
+				add(name, "field", spec.Name.Name)
+			}
+		}
+	case *ast.InterfaceType:
+		add(spec.Name, "interface", "")
+		for _, method := range t.Methods.List {
+			// Embedded interfaces and type constraints have no names
+			for _, name := range method.Names {
+				add(name, "method", spec.Name.Name)
+			}
+		}
+	default:
+		add(spec.Name, "type", "")
+	}
+}
+
+// goTypeIdent returns the identifier naming a type expression such as T,
+// *T, pkg.T or T[P], or nil for unnamed types.
+func goTypeIdent(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return goTypeIdent(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.IndexExpr:
+		return goTypeIdent(t.X)
+	case *ast.IndexListExpr:
+		return goTypeIdent(t.X)
+	case *ast.ParenExpr:
+		return goTypeIdent(t.X)
+	}
+	return nil
+}
+
+// goTypeName returns the name of the type in a method receiver.
+func goTypeName(expr ast.Expr) string {
+	if ident := goTypeIdent(expr); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
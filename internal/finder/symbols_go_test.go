@@ -0,0 +1,85 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractGoSymbols(t *testing.T) {
+	src := `package sample
+
+import "io"
+
+const (
+	MaxSize = 10
+	minSize = 1
+)
+
+var (
+	Default, fallback = New(), New()
+	_                 = io.EOF
+)
+
+type ID string
+
+type Alias = ID
+
+type Reader interface {
+	io.Closer
+	Read(p []byte) (int, error)
+}
+
+type Store[K comparable] struct {
+	io.Reader
+	items map[K]ID
+	Name  string
+}
+
+func New() *Store[string] { return nil }
+
+func (s *Store[K]) Get(key K) ID { return s.items[key] }
+
+func (Store[K]) len() int { return 0 }
+`
+
+	symbols, err := extractGoSymbols([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Symbol{
+		{Name: "MaxSize", Line: 6, Column: 1, Kind: "const", Exported: true},
+		{Name: "minSize", Line: 7, Column: 1, Kind: "const"},
+		{Name: "Default", Line: 11, Column: 1, Kind: "var", Exported: true},
+		{Name: "fallback", Line: 11, Column: 10, Kind: "var"},
+		{Name: "ID", Line: 15, Column: 5, Kind: "type", Exported: true},
+		{Name: "Alias", Line: 17, Column: 5, Kind: "alias", Exported: true},
+		{Name: "Reader", Line: 19, Column: 5, Kind: "interface", Exported: true},
+		{Name: "Read", Line: 21, Column: 1, Kind: "method", Receiver: "Reader", Exported: true},
+		{Name: "Store", Line: 24, Column: 5, Kind: "struct", Exported: true},
+		{Name: "Reader", Line: 25, Column: 4, Kind: "field", Receiver: "Store", Exported: true},
+		{Name: "items", Line: 26, Column: 1, Kind: "field", Receiver: "Store"},
+		{Name: "Name", Line: 27, Column: 1, Kind: "field", Receiver: "Store", Exported: true},
+		{Name: "New", Line: 30, Column: 5, Kind: "func", Exported: true},
+		{Name: "Get", Line: 32, Column: 19, Kind: "method", Receiver: "Store", Exported: true},
+		{Name: "len", Line: 34, Column: 16, Kind: "method", Receiver: "Store"},
+	}
+
+	if len(symbols) != len(expected) {
+		t.Fatalf("expected %d symbols, got %d: %+v", len(expected), len(symbols), symbols)
+	}
+	for i, want := range expected {
+		if !reflect.DeepEqual(symbols[i], want) {
+			t.Errorf("symbol %d: expected %+v, got %+v", i, want, symbols[i])
+		}
+	}
+}
+
+func TestExtractGoSymbols_SyntaxError(t *testing.T) {
+	src := "package broken\n\nfunc Good() {}\n\nfunc Bad( {\n"
+
+	symbols, _ := extractGoSymbols([]byte(src))
+	if len(symbols) == 0 || symbols[0].Name != "Good" {
+		t.Errorf("expected declarations before the error to be kept, got %+v", symbols)
+	}
+}
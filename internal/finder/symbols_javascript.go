@@ -0,0 +1,809 @@
+package finder
+
+import (
+	"strings"
+)
+
+// jsTokenKind classifies the tokens of JavaScript and TypeScript source.
+type jsTokenKind int
+
+const (
+	jsIdent jsTokenKind = iota
+	jsPunct
+	jsString // string literal or a piece of a template literal
+	jsNumber
+	jsRegexp
+	jsJSX // a whole JSX element
+	jsEOF
+)
+
+// jsToken is a token with its 1-based line and 0-based byte column.
+type jsToken struct {
+	kind    jsTokenKind
+	text    string
+	line    int
+	column  int
+	newline bool // a line break precedes the token
+}
+
+// jsPunctuators are the multi-character punctuators, longest first so that
+// the first prefix found is the longest match.
+var jsPunctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+}
+
+// jsRegexpKeywords are the keywords after which a slash starts a regular
+// expression rather than a division.
+var jsRegexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// jsLexer splits JavaScript or TypeScript source into tokens. Comments are
+// dropped, and strings, template literals, regular expressions and, when
+// jsx is set, JSX elements each become opaque tokens so that their content
+// is never mistaken for code.
+type jsLexer struct {
+	src       []byte
+	pos       int
+	line      int
+	lineStart int
+	jsx       bool
+
+	prev      jsToken // last token, to tell regular expressions from division
+	braces    int     // depth of open braces
+	templates []int   // brace depth of each open template substitution
+	newline   bool
+
+	// jsxIdents are the identifiers inside JSX elements, in tag names and
+	// embedded expressions, which are not returned as tokens.
+	jsxIdents []jsToken
+}
+
+func newJSLexer(src []byte, jsx bool) *jsLexer {
+	return &jsLexer{src: src, line: 1, jsx: jsx, prev: jsToken{kind: jsEOF}}
+}
+
+// tokens returns all tokens of the source.
+func (l *jsLexer) tokens() []jsToken {
+	var toks []jsToken
+	for {
+		tok := l.next()
+		if tok.kind == jsEOF {
+			return toks
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// next scans the next token.
+func (l *jsLexer) next() jsToken {
+	l.skipSpace()
+	if l.pos >= len(l.src) {
+		return jsToken{kind: jsEOF}
+	}
+
+	tok := jsToken{line: l.line, column: l.pos - l.lineStart, newline: l.newline}
+	l.newline = false
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case isJSIdentByte(c) || c == '#':
+		l.pos++
+		for l.pos < len(l.src) && (isJSIdentByte(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		tok.kind = jsIdent
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		for l.pos < len(l.src) && (isJSIdentByte(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		tok.kind = jsNumber
+
+	case c == '\'' || c == '"':
+		l.skipQuoted(c)
+		tok.kind = jsString
+
+	case c == '`':
+		l.pos++
+		l.skipTemplate()
+		tok.kind = jsString
+
+	case c == '}' && len(l.templates) > 0 && l.templates[len(l.templates)-1] == l.braces-1:
+		// The end of a template substitution resumes the template
+		l.templates = l.templates[:len(l.templates)-1]
+		l.braces--
+		l.pos++
+		l.skipTemplate()
+		tok.kind = jsString
+
+	case c == '/' && l.regexpAllowed():
+		l.skipRegexp()
+		tok.kind = jsRegexp
+
+	case c == '<' && l.jsx && l.regexpAllowed() && l.pos+1 < len(l.src) &&
+		(isJSIdentByte(l.src[l.pos+1]) || l.src[l.pos+1] == '>'):
+		l.skipJSXElement()
+		tok.kind = jsJSX
+
+	default:
+		tok.kind = jsPunct
+		l.pos++
+		rest := string(l.src[start:min(start+4, len(l.src))])
+		for _, p := range jsPunctuators {
+			if strings.HasPrefix(rest, p) {
+				l.pos = start + len(p)
+				break
+			}
+		}
+		switch c {
+		case '{':
+			l.braces++
+		case '}':
+			l.braces--
+		}
+	}
+
+	tok.text = string(l.src[start:l.pos])
+	l.prev = tok
+	return tok
+}
+
+// skipSpace skips whitespace and comments, noting line breaks.
+func (l *jsLexer) skipSpace() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.newLine()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case c == '/' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '/':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '/' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '*':
+			l.pos += 2
+			for l.pos < len(l.src) && !(l.src[l.pos] == '*' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '/') {
+				l.advance()
+			}
+			l.pos = min(l.pos+2, len(l.src))
+		default:
+			return
+		}
+	}
+}
+
+// advance moves past one byte, keeping track of lines.
+func (l *jsLexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.newLine()
+		return
+	}
+	l.pos++
+}
+
+func (l *jsLexer) newLine() {
+	l.pos++
+	l.line++
+	l.lineStart = l.pos
+	l.newline = true
+}
+
+// skipQuoted skips a string literal delimited by quote. An unescaped line
+// break ends an unterminated string.
+func (l *jsLexer) skipQuoted(quote byte) {
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case quote:
+			l.pos++
+			return
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) {
+				l.advance()
+			}
+		case '\n':
+			return
+		default:
+			l.pos++
+		}
+	}
+}
+
+// skipTemplate skips template literal text up to its closing backtick or
+// the start of a substitution, whose code is then scanned as tokens.
+func (l *jsLexer) skipTemplate() {
+	for l.pos < len(l.src) {
+		switch {
+		case l.src[l.pos] == '`':
+			l.pos++
+			return
+		case l.src[l.pos] == '\\':
+			l.pos++
+			if l.pos < len(l.src) {
+				l.advance()
+			}
+		case l.src[l.pos] == '$' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '{':
+			l.pos += 2
+			l.templates = append(l.templates, l.braces)
+			l.braces++
+			return
+		default:
+			l.advance()
+		}
+	}
+}
+
+// skipRegexp skips a regular expression literal and its flags.
+func (l *jsLexer) skipRegexp() {
+	l.pos++
+	inClass := false
+	for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		c := l.src[l.pos]
+		l.pos++
+		switch {
+		case c == '\\':
+			if l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			for l.pos < len(l.src) && isJSIdentByte(l.src[l.pos]) {
+				l.pos++
+			}
+			return
+		}
+	}
+}
+
+// regexpAllowed reports whether an expression may start after the previous
+// token, in which case a slash starts a regular expression and a "<" a JSX
+// element.
+func (l *jsLexer) regexpAllowed() bool {
+	switch l.prev.kind {
+	case jsEOF:
+		return true
+	case jsIdent:
+		return jsRegexpKeywords[l.prev.text]
+	case jsPunct:
+		switch l.prev.text {
+		case ")", "]", "++", "--":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// skipJSXElement skips a JSX element or fragment starting at "<", including
+// its children and closing tag.
+func (l *jsLexer) skipJSXElement() {
+	l.pos++
+	l.jsxTagName()
+
+	// Tag name and attributes
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '/' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '>':
+			l.pos += 2
+			return
+		case c == '>':
+			l.pos++
+			l.skipJSXChildren()
+			return
+		case c == '{':
+			l.skipJSXExpression()
+		case c == '"' || c == '\'':
+			// Attribute strings may span lines and have no escapes
+			l.pos++
+			for l.pos < len(l.src) && l.src[l.pos] != c {
+				l.advance()
+			}
+			l.pos = min(l.pos+1, len(l.src))
+		default:
+			l.advance()
+		}
+	}
+}
+
+// skipJSXChildren skips the children of a JSX element and its closing tag.
+func (l *jsLexer) skipJSXChildren() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '<':
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '/' {
+				l.pos += 2
+				l.jsxTagName()
+				for l.pos < len(l.src) && l.src[l.pos] != '>' {
+					l.advance()
+				}
+				l.pos = min(l.pos+1, len(l.src))
+				return
+			}
+			l.skipJSXElement()
+		case '{':
+			l.skipJSXExpression()
+		default:
+			l.advance()
+		}
+	}
+}
+
+// skipJSXExpression skips a braced JavaScript expression inside JSX by
+// scanning it as tokens up to the matching closing brace.
+func (l *jsLexer) skipJSXExpression() {
+	base := l.braces
+	l.braces++
+	l.pos++
+	l.prev = jsToken{kind: jsPunct, text: "{"}
+	for {
+		tok := l.next()
+		if tok.kind == jsEOF || (tok.kind == jsPunct && tok.text == "}" && l.braces == base) {
+			return
+		}
+		if tok.kind == jsIdent {
+			l.jsxIdents = append(l.jsxIdents, tok)
+		}
+	}
+}
+
+// jsxTagName skips a JSX tag name such as Foo.Bar or my-element, recording
+// its identifiers.
+func (l *jsLexer) jsxTagName() {
+	for {
+		start := l.pos
+		for l.pos < len(l.src) && (isJSIdentByte(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if l.pos > start {
+			l.jsxIdents = append(l.jsxIdents, jsToken{
+				kind:   jsIdent,
+				text:   string(l.src[start:l.pos]),
+				line:   l.line,
+				column: start - l.lineStart,
+			})
+		}
+		if l.pos >= len(l.src) || l.src[l.pos] != '.' {
+			return
+		}
+		l.pos++
+	}
+}
+
+func isJSIdentByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// jsScope is an open bracket while extracting symbols. Declarations are only
+// recognized directly inside module, namespace and class scopes.
+type jsScope struct {
+	kind  string // "module", "namespace", "class", "block" or "paren"
+	class string // name of the class whose body this is
+}
+
+// jsExtractor recognizes declarations in a token stream.
+type jsExtractor struct {
+	toks    []jsToken
+	bodies  map[int]jsScope // scopes opened by the brace at a token index
+	symbols []Symbol
+}
+
+// extractJSSymbols extracts symbols from JavaScript and TypeScript code
+// with a tokenizer, so that strings, comments, template literals and JSX
+// (when jsx is set) are never taken for code. It reports module and
+// namespace level functions (including arrow functions assigned to
+// variables), classes, interfaces, type aliases, enums, namespaces and
+// variables, and the methods and properties of classes.
+func extractJSSymbols(content []byte, jsx bool) ([]Symbol, error) {
+	x := &jsExtractor{
+		toks:   newJSLexer(content, jsx).tokens(),
+		bodies: make(map[int]jsScope),
+	}
+
+	scopes := []jsScope{{kind: "module"}}
+	for i, tok := range x.toks {
+		top := scopes[len(scopes)-1]
+		if x.statementStart(i) {
+			switch top.kind {
+			case "module", "namespace":
+				x.declaration(i)
+			case "class":
+				x.member(i, top.class)
+			}
+		}
+
+		if tok.kind != jsPunct {
+			continue
+		}
+		switch tok.text {
+		case "{":
+			scope, ok := x.bodies[i]
+			if !ok {
+				scope = jsScope{kind: "block"}
+			}
+			scopes = append(scopes, scope)
+		case "(", "[":
+			scopes = append(scopes, jsScope{kind: "paren"})
+		case "}", ")", "]":
+			if len(scopes) > 1 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+	}
+
+	return x.symbols, nil
+}
+
+// statementStart reports whether token i may begin a declaration or class
+// member: it follows a semicolon or brace, or a line break that does not
+// continue an expression.
+func (x *jsExtractor) statementStart(i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := x.toks[i-1]
+	if prev.kind == jsPunct {
+		switch prev.text {
+		case ";", "{", "}":
+			return true
+		case ")", "]":
+			return x.toks[i].newline
+		}
+		return false
+	}
+	return x.toks[i].newline
+}
+
+// tok returns token i, or an EOF token past the end.
+func (x *jsExtractor) tok(i int) jsToken {
+	if i < len(x.toks) {
+		return x.toks[i]
+	}
+	return jsToken{kind: jsEOF}
+}
+
+// is reports whether token i is the given identifier or punctuator.
+func (x *jsExtractor) is(i int, text string) bool {
+	t := x.tok(i)
+	return (t.kind == jsIdent || t.kind == jsPunct) && t.text == text
+}
+
+func (x *jsExtractor) add(tok jsToken, kind string, receiver string, exported bool) {
+	x.symbols = append(x.symbols, Symbol{
+		Name:     tok.text,
+		Line:     tok.line,
+		Column:   tok.column,
+		Kind:     kind,
+		Receiver: receiver,
+		Exported: exported,
+	})
+}
+
+// jsDeclarationModifiers may precede a module level declaration keyword.
+var jsDeclarationModifiers = map[string]bool{
+	"export": true, "default": true, "declare": true, "abstract": true, "async": true,
+}
+
+// jsMemberModifiers may precede the name of a class member.
+var jsMemberModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "static": true, "readonly": true,
+	"abstract": true, "async": true, "override": true, "declare": true, "accessor": true,
+	"get": true, "set": true, "*": true,
+}
+
+// declaration recognizes a module or namespace level declaration at i.
+func (x *jsExtractor) declaration(i int) {
+	exported := false
+	for x.tok(i).kind == jsIdent && jsDeclarationModifiers[x.tok(i).text] {
+		if x.tok(i).text == "export" {
+			exported = true
+		}
+		i++
+	}
+	if x.tok(i).kind != jsIdent {
+		return
+	}
+
+	name := x.tok(i + 1)
+	named := name.kind == jsIdent
+	switch x.tok(i).text {
+	case "function":
+		if x.is(i+1, "*") {
+			name, named = x.tok(i+2), x.tok(i+2).kind == jsIdent
+		}
+		if named {
+			x.add(name, "function", "", exported)
+		}
+	case "class":
+		if named && name.text != "extends" && name.text != "implements" {
+			x.add(name, "class", "", exported)
+			x.openBody(i+2, jsScope{kind: "class", class: name.text})
+		} else {
+			x.openBody(i+1, jsScope{kind: "class", class: "default"})
+		}
+	case "interface":
+		if named {
+			x.add(name, "interface", "", exported)
+		}
+	case "type":
+		if named && (x.is(i+2, "=") || x.is(i+2, "<")) {
+			x.add(name, "type", "", exported)
+		}
+	case "enum":
+		if named {
+			x.add(name, "enum", "", exported)
+		}
+	case "namespace", "module":
+		if named || name.kind == jsString {
+			if named {
+				x.add(name, "namespace", "", exported)
+			}
+			x.openBody(i+2, jsScope{kind: "namespace"})
+		}
+	case "const", "let", "var":
+		if x.is(i+1, "enum") {
+			if x.tok(i+2).kind == jsIdent {
+				x.add(x.tok(i+2), "enum", "", exported)
+			}
+			return
+		}
+		x.declarators(i+1, exported)
+	}
+}
+
+// declarators reports each variable of the declaration list whose first
+// name is at i, as in "let a = 1, b = () => 2", with those initialized to a
+// function reported as functions. Destructuring patterns are skipped.
+func (x *jsExtractor) declarators(i int, exported bool) {
+	for i >= 0 {
+		if name := x.tok(i); name.kind == jsIdent {
+			kind := "variable"
+			if x.functionInitializer(i + 1) {
+				kind = "function"
+			}
+			x.add(name, kind, "", exported)
+		}
+		i = x.nextDeclarator(i)
+	}
+}
+
+// nextDeclarator returns the index of the name following the declarator
+// at i, or -1 when it ends the declaration.
+func (x *jsExtractor) nextDeclarator(i int) int {
+	if x.tok(i).kind == jsIdent {
+		i++
+		if x.is(i, "!") {
+			i++
+		}
+		if x.is(i, ":") {
+			i = x.skipType(i + 1)
+		}
+	}
+	depth := 0
+	for ; i < len(x.toks); i++ {
+		t := x.toks[i]
+		if depth == 0 && t.newline && x.statementStart(i) {
+			return -1
+		}
+		if t.kind != jsPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			if depth--; depth < 0 {
+				return -1
+			}
+		case ";":
+			if depth == 0 {
+				return -1
+			}
+		case ",":
+			if depth == 0 && x.isDeclaratorName(i+1) {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// isDeclaratorName reports whether token i can be the name of a declarator
+// after a comma, rather than, say, a type argument as in "f<A, B>()".
+func (x *jsExtractor) isDeclaratorName(i int) bool {
+	if x.tok(i).kind != jsIdent {
+		return x.is(i, "{") || x.is(i, "[")
+	}
+	next := x.tok(i + 1)
+	return next.kind == jsEOF || next.newline ||
+		x.is(i+1, "=") || x.is(i+1, ":") || x.is(i+1, ",") || x.is(i+1, ";") || x.is(i+1, "!")
+}
+
+// member recognizes a class member declaration at i.
+func (x *jsExtractor) member(i int, class string) {
+	// Skip decorators such as @Input() or @api.field
+	for x.is(i, "@") {
+		i += 2
+		for x.is(i, ".") {
+			i += 2
+		}
+		if x.is(i, "(") {
+			i = x.skipBalanced(i) + 1
+		}
+	}
+
+	exported := true
+	for x.isMemberModifier(i) {
+		if x.is(i, "private") || x.is(i, "protected") {
+			exported = false
+		}
+		i++
+	}
+
+	name := x.tok(i)
+	switch name.kind {
+	case jsIdent:
+		if strings.HasPrefix(name.text, "#") {
+			exported = false
+		}
+	case jsString:
+		name.text = strings.Trim(name.text, `'"`)
+		name.column++
+	default:
+		return // Computed names and index signatures
+	}
+
+	j := i + 1
+	if x.is(j, "?") || x.is(j, "!") {
+		j++
+	}
+	switch {
+	case x.is(j, "(") || x.is(j, "<"):
+		x.add(name, "method", class, exported)
+	case x.is(j, "=") || x.is(j, ":"):
+		kind := "property"
+		if x.functionInitializer(j) {
+			kind = "method"
+		}
+		x.add(name, kind, class, exported)
+	case x.is(j, ";") || x.is(j, "}") || x.tok(j).newline || x.tok(j).kind == jsEOF:
+		x.add(name, "property", class, exported)
+	}
+}
+
+// isMemberModifier reports whether token i is a modifier of a class member
+// rather than its name, which it is only when another name follows.
+func (x *jsExtractor) isMemberModifier(i int) bool {
+	t := x.tok(i)
+	if (t.kind != jsIdent && !x.is(i, "*")) || !jsMemberModifiers[t.text] {
+		return false
+	}
+	next := x.tok(i + 1)
+	return next.kind == jsIdent || next.kind == jsString || x.is(i+1, "*") || x.is(i+1, "[")
+}
+
+// openBody marks the first brace from i that is not nested in parentheses,
+// brackets or type arguments as opening scope.
+func (x *jsExtractor) openBody(i int, scope jsScope) {
+	depth := 0
+	for ; i < len(x.toks); i++ {
+		t := x.toks[i]
+		if t.kind != jsPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "<":
+			depth++
+		case ")", "]", ">":
+			depth--
+		case ">>", ">>>":
+			depth -= len(t.text)
+		case "{":
+			if depth <= 0 {
+				x.bodies[i] = scope
+				return
+			}
+			i = x.skipBalanced(i)
+		case ";":
+			return
+		}
+	}
+}
+
+// functionInitializer reports whether the tokens from i, an optional type
+// annotation followed by an initializer, assign a function or an arrow
+// function.
+func (x *jsExtractor) functionInitializer(i int) bool {
+	if x.is(i, ":") {
+		i = x.skipType(i + 1)
+	}
+	if !x.is(i, "=") {
+		return false
+	}
+	i++
+	if x.is(i, "async") {
+		i++
+	}
+	switch {
+	case x.is(i, "function"):
+		return true
+	case x.tok(i).kind == jsIdent:
+		return x.is(i+1, "=>")
+	case x.is(i, "<"):
+		i = x.skipType(i)
+		if !x.is(i, "(") {
+			return false
+		}
+		fallthrough
+	case x.is(i, "("):
+		i = x.skipBalanced(i) + 1
+		if x.is(i, ":") {
+			i = x.skipType(i + 1)
+		}
+		return x.is(i, "=>")
+	}
+	return false
+}
+
+// skipType skips a type annotation starting at i and returns the index of
+// the token ending it: "=", "=>", ";" or "," outside any brackets.
+func (x *jsExtractor) skipType(i int) int {
+	depth := 0
+	for ; i < len(x.toks); i++ {
+		t := x.toks[i]
+		if t.kind != jsPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{", "<":
+			depth++
+		case ")", "]", "}", ">":
+			depth--
+		case ">>", ">>>":
+			depth -= len(t.text)
+		case "=", "=>", ";", ",":
+			if depth <= 0 {
+				return i
+			}
+		}
+		if depth < 0 {
+			return i
+		}
+	}
+	return i
+}
+
+// skipBalanced returns the index of the bracket closing the one at i.
+func (x *jsExtractor) skipBalanced(i int) int {
+	depth := 0
+	for ; i < len(x.toks); i++ {
+		if x.toks[i].kind != jsPunct {
+			continue
+		}
+		switch x.toks[i].text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,199 @@
+package finder
+
+import (
+	"testing"
+)
+
+// symbolSummary is the part of a symbol checked by the extractor tests.
+type symbolSummary struct {
+	Name     string
+	Kind     string
+	Receiver string
+	Line     int
+}
+
+func summarizeSymbols(symbols []Symbol) []symbolSummary {
+	var summaries []symbolSummary
+	for _, s := range symbols {
+		summaries = append(summaries, symbolSummary{s.Name, s.Kind, s.Receiver, s.Line})
+	}
+	return summaries
+}
+
+func assertSymbols(t *testing.T, got []Symbol, want []symbolSummary) {
+	t.Helper()
+	summaries := summarizeSymbols(got)
+	if len(summaries) != len(want) {
+		t.Fatalf("expected %d symbols, got %d:\n%+v", len(want), len(summaries), summaries)
+	}
+	for i := range want {
+		if summaries[i] != want[i] {
+			t.Errorf("symbol %d: expected %+v, got %+v", i, want[i], summaries[i])
+		}
+	}
+}
+
+func TestExtractJSSymbols_TypeScript(t *testing.T) {
+	src := `import { thing } from "./thing";
+
+// function commented() {}
+/* class Commented {} */
+const message = "function fake() {}";
+const template = ` + "`class Fake { ${thing({ a: 1 })} }`" + `;
+const pattern = /class Nope {/g;
+
+export interface Shape {
+	area(): number;
+}
+
+export type ID = string | number;
+type Pair<T> = [T, T];
+
+export enum Color { Red, Green }
+const enum Flags { A = 1 }
+
+export const handler = async (req: Request): Promise<void> => {
+	if (req) {
+		for (const x of []) {}
+	}
+};
+let counter = 0;
+const typed: Handler = function () {};
+
+export default class Circle extends Base<{ r: number }> implements Shape {
+	@observable radius = 1;
+	private readonly cache: Map<string, number>;
+	static count = 0
+	#secret = 2;
+	onClick = (e: Event) => {};
+
+	constructor(radius: number) {
+		super();
+		if (radius > 0) {
+			this.radius = radius;
+		}
+	}
+
+	get diameter(): number { return this.radius * 2; }
+
+	async *points<T>(): AsyncGenerator<T> {}
+
+	area(): number {
+		return Math.PI * this.radius ** 2;
+	}
+}
+
+export function* ids() {}
+
+namespace Geometry {
+	export function scale() {}
+}
+`
+
+	symbols, err := extractJSSymbols([]byte(src), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSymbols(t, symbols, []symbolSummary{
+		{"message", "variable", "", 5},
+		{"template", "variable", "", 6},
+		{"pattern", "variable", "", 7},
+		{"Shape", "interface", "", 9},
+		{"ID", "type", "", 13},
+		{"Pair", "type", "", 14},
+		{"Color", "enum", "", 16},
+		{"Flags", "enum", "", 17},
+		{"handler", "function", "", 19},
+		{"counter", "variable", "", 24},
+		{"typed", "function", "", 25},
+		{"Circle", "class", "", 27},
+		{"radius", "property", "Circle", 28},
+		{"cache", "property", "Circle", 29},
+		{"count", "property", "Circle", 30},
+		{"#secret", "property", "Circle", 31},
+		{"onClick", "method", "Circle", 32},
+		{"constructor", "method", "Circle", 34},
+		{"diameter", "method", "Circle", 41},
+		{"points", "method", "Circle", 43},
+		{"area", "method", "Circle", 45},
+		{"ids", "function", "", 50},
+		{"Geometry", "namespace", "", 52},
+		{"scale", "function", "", 53},
+	})
+
+	if symbols[11].Column != 21 {
+		t.Errorf("expected Circle at column 21, got %d", symbols[11].Column)
+	}
+
+	// Exported status follows export modifiers and member visibility
+	exported := make(map[string]bool)
+	for _, s := range symbols {
+		exported[s.Name] = s.Exported
+	}
+	for name, want := range map[string]bool{"Shape": true, "Pair": false, "Circle": true, "cache": false, "#secret": false, "area": true} {
+		if exported[name] != want {
+			t.Errorf("expected %s exported=%v", name, want)
+		}
+	}
+}
+
+func TestExtractJSSymbols_JSX(t *testing.T) {
+	src := `export function App({ items }) {
+	return (
+		<div className="app" title='it&apos;s'>
+			<p>Don't function broken() {"{"}</p>
+			{items.map(item => <Item key={item.id} {...item} />)}
+			<>fragment</>
+		</div>
+	);
+}
+
+export const Item = (props) => <li>{props.name}</li>;
+
+class Legacy extends React.Component {
+	render() {
+		return <span>{this.props.x > 1 ? "a" : 'b'}</span>;
+	}
+}
+`
+
+	symbols, err := extractJSSymbols([]byte(src), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSymbols(t, symbols, []symbolSummary{
+		{"App", "function", "", 1},
+		{"Item", "function", "", 11},
+		{"Legacy", "class", "", 13},
+		{"render", "method", "Legacy", 14},
+	})
+}
+
+func TestExtractJSSymbols_Declarators(t *testing.T) {
+	src := `let a = 1, b = () => 2;
+export const c = { x: [1, 2] }, d = async function () {},
+	e: Map<string, number> = new Map<string, number>(), [f, g] = pair, h;
+var i = call(1, 2)
+const j = k < l, m = (n) => n, o = p<Q, R>(s)
+`
+
+	symbols, err := extractJSSymbols([]byte(src), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSymbols(t, symbols, []symbolSummary{
+		{"a", "variable", "", 1},
+		{"b", "function", "", 1},
+		{"c", "variable", "", 2},
+		{"d", "function", "", 2},
+		{"e", "variable", "", 3},
+		{"h", "variable", "", 3},
+		{"i", "variable", "", 4},
+		{"j", "variable", "", 5},
+		{"m", "function", "", 5},
+		{"o", "variable", "", 5},
+	})
+}
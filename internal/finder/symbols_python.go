@@ -0,0 +1,239 @@
+package finder
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	// pyDefRe matches a function or class definition after its indentation.
+	pyDefRe = regexp.MustCompile(`^(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)`)
+	// pyAssignRe matches a plain or annotated assignment to a single name.
+	pyAssignRe = regexp.MustCompile(`^([A-Za-z_]\w*)\s*(?::\s*\S|=(?:[^=]|$))`)
+)
+
+// pyKeywords are the keywords that could otherwise be taken for the target
+// of an annotated assignment, as in "else:".
+var pyKeywords = map[string]bool{
+	"else": true, "try": true, "finally": true, "except": true, "lambda": true,
+	"match": true, "case": true, "if": true, "elif": true, "while": true, "for": true,
+	"with": true, "return": true, "yield": true, "pass": true, "break": true, "continue": true,
+}
+
+// pyBlock is an open class or function body.
+type pyBlock struct {
+	indent int
+	kind   string // "class" or "def"
+	name   string // qualified name
+}
+
+// extractPythonSymbols extracts symbols from Python code. It follows the
+// indentation of class and function bodies to tell methods from functions
+// and to qualify names with their enclosing definitions, as in
+// "Client.fetch", which are stored as the symbol's receiver. Definitions may
+// be async and decorated, and the decorators are recorded without their "@".
+// Module-level and class-level assignments, annotated or not, are reported
+// as variables and properties. Strings and comments are never taken for
+// code.
+func extractPythonSymbols(content []byte) ([]Symbol, error) {
+	lines := bytes.Split(content, []byte("\n"))
+	masked, inString := maskPythonStrings(content)
+	code := bytes.Split(masked, []byte("\n"))
+
+	var symbols []Symbol
+	var blocks []pyBlock
+	var decorators []string
+	depth := 0
+	continued := false
+
+	for i, masked := range code {
+		// Only the first physical line of a logical line starts a statement
+		starts := depth == 0 && !continued && !inString[i]
+		for _, c := range masked {
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth = max(depth-1, 0)
+			}
+		}
+		trimmed := bytes.TrimRight(masked, " \t\r")
+		continued = bytes.HasSuffix(trimmed, []byte("\\"))
+
+		stmt := bytes.TrimLeft(trimmed, " \t\f")
+		if !starts || len(stmt) == 0 {
+			continue
+		}
+		indent := pythonIndent(masked)
+		for len(blocks) > 0 && blocks[len(blocks)-1].indent >= indent {
+			blocks = blocks[:len(blocks)-1]
+		}
+		column := len(trimmed) - len(stmt)
+
+		if stmt[0] == '@' {
+			// The masked line tells where the code ends before a comment
+			decorators = append(decorators, strings.TrimSpace(string(lines[i][column+1:len(trimmed)])))
+			continue
+		}
+
+		var parent *pyBlock
+		if len(blocks) > 0 {
+			parent = &blocks[len(blocks)-1]
+		}
+
+		if m := pyDefRe.FindSubmatchIndex(stmt); m != nil {
+			keyword := string(stmt[m[2]:m[3]])
+			name := string(stmt[m[4]:m[5]])
+
+			kind := "class"
+			if keyword == "def" {
+				kind = "function"
+				if parent != nil && parent.kind == "class" {
+					kind = "method"
+					for _, d := range decorators {
+						if d == "property" || strings.HasSuffix(d, ".setter") || strings.HasSuffix(d, ".getter") {
+							kind = "property"
+						}
+					}
+				}
+			}
+
+			symbol := Symbol{
+				Name:       name,
+				Line:       i + 1,
+				Column:     column + m[4],
+				Kind:       kind,
+				Exported:   isPythonPublic(name),
+				Decorators: decorators,
+			}
+			if parent != nil {
+				symbol.Receiver = parent.name
+			}
+			symbols = append(symbols, symbol)
+
+			blocks = append(blocks, pyBlock{indent: indent, kind: keyword, name: symbol.QualifiedName()})
+			decorators = nil
+			continue
+		}
+		decorators = nil
+
+		// Assignments in a function body are locals
+		if parent != nil && parent.kind != "class" {
+			continue
+		}
+		if m := pyAssignRe.FindSubmatchIndex(stmt); m != nil {
+			name := string(stmt[m[2]:m[3]])
+			if pyKeywords[name] {
+				continue
+			}
+			symbol := Symbol{
+				Name:     name,
+				Line:     i + 1,
+				Column:   column,
+				Kind:     "variable",
+				Exported: isPythonPublic(name),
+			}
+			if parent != nil {
+				symbol.Kind = "property"
+				symbol.Receiver = parent.name
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols, nil
+}
+
+// maskPythonStrings returns a copy of content with the contents of string
+// literals and comments replaced by spaces, keeping quotes, line breaks and
+// byte offsets intact. It also reports which lines start inside a string.
+func maskPythonStrings(content []byte) (masked []byte, inString map[int]bool) {
+	masked = bytes.Clone(content)
+	inString = make(map[int]bool)
+	line := 0
+	blank := func(from int, to int) {
+		for k := from; k < to; k++ {
+			if masked[k] == '\n' {
+				line++
+				inString[line] = true
+			} else {
+				masked[k] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(content); {
+		switch c := content[i]; c {
+		case '#':
+			end := bytes.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			blank(i, i+end)
+			i += end
+
+		case '\'', '"':
+			quote := content[i : i+1]
+			if bytes.HasPrefix(content[i:], []byte{c, c, c}) {
+				quote = content[i : i+3]
+			}
+			start := i + len(quote)
+			j := start
+			closed := false
+			for j < len(content) {
+				if content[j] == '\\' {
+					j += 2
+					continue
+				}
+				if bytes.HasPrefix(content[j:], quote) {
+					closed = true
+					break
+				}
+				if content[j] == '\n' && len(quote) == 1 {
+					break // Unterminated string
+				}
+				j++
+			}
+			j = min(j, len(content))
+			blank(start, j)
+			i = j
+			if closed {
+				i += len(quote)
+			}
+
+		case '\n':
+			line++
+			i++
+
+		default:
+			i++
+		}
+	}
+	return masked, inString
+}
+
+// pythonIndent returns the width of the indentation of line, with tabs
+// advancing to the next multiple of eight as in Python.
+func pythonIndent(line []byte) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width = (width/8 + 1) * 8
+		case '\f':
+			width = 0
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// isPythonPublic reports whether name is public by Python's convention:
+// it does not start with an underscore, unless it is a dunder name.
+func isPythonPublic(name string) bool {
+	return !strings.HasPrefix(name, "_") || (len(name) > 4 && strings.HasSuffix(name, "__") && strings.HasPrefix(name, "__"))
+}
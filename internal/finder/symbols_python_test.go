@@ -0,0 +1,111 @@
+package finder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractPythonSymbols(t *testing.T) {
+	src := `"""Module docstring.
+
+def not_a_function():
+class NotAClass:
+"""
+import os
+
+API_URL: str = "https://example.com"  # def fake(): pass
+TIMEOUT = 30
+_cache = {}
+registry: dict
+if TIMEOUT == 30:
+    pass
+else:
+    pass
+
+@dataclass(frozen=True)
+class Config:
+    name: str
+    retries: int = 3
+
+    def validate(self):
+        local = 1
+        def check():
+            pass
+        return check()
+
+
+class Client(Base):
+    '''Client docstring with def fake(): and
+class Fake: inside.
+'''
+    session = None
+
+    def __init__(self, url,
+                 timeout=TIMEOUT):
+        self.url = url
+
+    @property
+    def host(self):
+        return self.url
+
+    @staticmethod
+    @cache  # a comment
+    async def fetch(path="def nope():"):
+        return path
+
+    class Meta:
+        ordering = ["-id"]
+
+        def _private(self):
+            pass
+
+
+async def main():
+    pass
+`
+
+	symbols, err := extractPythonSymbols([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSymbols(t, symbols, []symbolSummary{
+		{"API_URL", "variable", "", 8},
+		{"TIMEOUT", "variable", "", 9},
+		{"_cache", "variable", "", 10},
+		{"registry", "variable", "", 11},
+		{"Config", "class", "", 18},
+		{"name", "property", "Config", 19},
+		{"retries", "property", "Config", 20},
+		{"validate", "method", "Config", 22},
+		{"check", "function", "Config.validate", 24},
+		{"Client", "class", "", 29},
+		{"session", "property", "Client", 33},
+		{"__init__", "method", "Client", 35},
+		{"host", "property", "Client", 40},
+		{"fetch", "method", "Client", 45},
+		{"Meta", "class", "Client", 48},
+		{"ordering", "property", "Client.Meta", 49},
+		{"_private", "method", "Client.Meta", 51},
+		{"main", "function", "", 55},
+	})
+
+	byName := make(map[string]Symbol)
+	for _, s := range symbols {
+		byName[s.QualifiedName()] = s
+	}
+	if got := byName["Client.fetch"].Decorators; !reflect.DeepEqual(got, []string{"staticmethod", "cache"}) {
+		t.Errorf("unexpected decorators for Client.fetch: %q", got)
+	}
+	if got := byName["Config"].Decorators; !reflect.DeepEqual(got, []string{"dataclass(frozen=True)"}) {
+		t.Errorf("unexpected decorators for Config: %q", got)
+	}
+	if byName["Client.fetch"].Column != 14 {
+		t.Errorf("expected fetch at column 14, got %d", byName["Client.fetch"].Column)
+	}
+	for name, want := range map[string]bool{"_cache": false, "Client.__init__": true, "Client.Meta._private": false, "main": true} {
+		if byName[name].Exported != want {
+			t.Errorf("expected %s exported=%v", name, want)
+		}
+	}
+}
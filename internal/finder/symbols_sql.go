@@ -0,0 +1,341 @@
+package finder
+
+import (
+	"bytes"
+	"strings"
+)
+
+// sqlTokenKind classifies the tokens of SQL source.
+type sqlTokenKind int
+
+const (
+	sqlWord   sqlTokenKind = iota // keyword, identifier or number
+	sqlQuoted                     // quoted identifier: "name", `name` or [name]
+	sqlString                     // string literal, including dollar-quoted bodies
+	sqlPunct
+)
+
+// sqlToken is a token with its 1-based line and 0-based byte column. The
+// text of a quoted identifier is its unquoted name.
+type sqlToken struct {
+	kind   sqlTokenKind
+	text   string
+	line   int
+	column int
+}
+
+// sqlObjectKinds maps the object keyword of a CREATE statement to the kind
+// of symbol it declares.
+var sqlObjectKinds = map[string]string{
+	"TABLE":     "table",
+	"VIEW":      "view",
+	"FUNCTION":  "function",
+	"PROCEDURE": "procedure",
+	"INDEX":     "index",
+	"TRIGGER":   "trigger",
+	"TYPE":      "type",
+	"SEQUENCE":  "sequence",
+	"SCHEMA":    "schema",
+	"DOMAIN":    "domain",
+}
+
+// sqlCreateModifiers are the words that may come between CREATE and the
+// object keyword, across PostgreSQL, MySQL, SQLite and SQL Server.
+var sqlCreateModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "ALTER": true, "TEMP": true, "TEMPORARY": true,
+	"GLOBAL": true, "LOCAL": true, "UNLOGGED": true, "UNIQUE": true, "CLUSTERED": true,
+	"NONCLUSTERED": true, "MATERIALIZED": true, "RECURSIVE": true, "CONSTRAINT": true,
+	"FOREIGN": true, "VIRTUAL": true, "SECURE": true, "FORCE": true, "NO": true,
+}
+
+// sqlOptionModifiers are MySQL view and routine options of the form
+// "NAME = value" that may come between CREATE and the object keyword.
+var sqlOptionModifiers = map[string]bool{
+	"DEFINER": true, "ALGORITHM": true,
+}
+
+// extractSQLSymbols extracts the objects created by CREATE statements in SQL
+// code: tables, views, materialized views, functions, procedures, indexes,
+// triggers, types, sequences, schemas and domains. It accepts CREATE OR
+// REPLACE, IF NOT EXISTS and similar modifiers, schema-qualified and quoted
+// names, whose schema is stored as the symbol's receiver. Comments, strings
+// and dollar-quoted bodies are never taken for statements.
+func extractSQLSymbols(content []byte) ([]Symbol, error) {
+	toks := tokenizeSQL(content)
+	tok := func(i int) sqlToken {
+		if i < len(toks) {
+			return toks[i]
+		}
+		return sqlToken{kind: sqlPunct}
+	}
+	word := func(i int) string {
+		if t := tok(i); t.kind == sqlWord {
+			return strings.ToUpper(t.text)
+		}
+		return ""
+	}
+
+	var symbols []Symbol
+	depth := 0
+	for i, t := range toks {
+		switch {
+		case t.kind == sqlPunct && t.text == "(":
+			depth++
+			continue
+		case t.kind == sqlPunct && t.text == ")":
+			depth = max(depth-1, 0)
+			continue
+		case depth > 0 || word(i) != "CREATE":
+			continue
+		}
+
+		// Skip modifiers up to the object keyword
+		j := i + 1
+		materialized := false
+		for {
+			w := word(j)
+			if sqlCreateModifiers[w] {
+				materialized = materialized || w == "MATERIALIZED"
+				j++
+			} else if w == "SQL" && word(j+1) == "SECURITY" {
+				j += 3
+			} else if sqlOptionModifiers[w] && tok(j+1).text == "=" {
+				// The value may be a user@host pair
+				j += 3
+				for tok(j).text == "@" {
+					j += 2
+				}
+			} else {
+				break
+			}
+		}
+
+		kind, ok := sqlObjectKinds[word(j)]
+		if !ok {
+			continue
+		}
+		if materialized && kind == "view" {
+			kind = "materialized_view"
+		}
+		j++
+
+		if word(j) == "CONCURRENTLY" {
+			j++
+		}
+		if word(j) == "IF" && word(j+1) == "NOT" && word(j+2) == "EXISTS" {
+			j += 3
+		}
+		// Unnamed indexes and schemas named only by their owner
+		if word(j) == "ON" || word(j) == "AUTHORIZATION" {
+			continue
+		}
+
+		// Collect a possibly qualified name such as schema.table
+		var parts []sqlToken
+		for {
+			part := tok(j)
+			if part.kind != sqlWord && part.kind != sqlQuoted {
+				break
+			}
+			parts = append(parts, part)
+			if tok(j+1).text != "." {
+				break
+			}
+			j += 2
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		name := parts[len(parts)-1]
+		var schema []string
+		for _, part := range parts[:len(parts)-1] {
+			schema = append(schema, part.text)
+		}
+		column := name.column
+		if name.kind == sqlQuoted {
+			column++
+		}
+		symbols = append(symbols, Symbol{
+			Name:     name.text,
+			Line:     name.line,
+			Column:   column,
+			Kind:     kind,
+			Receiver: strings.Join(schema, "."),
+			Exported: true,
+		})
+	}
+
+	return symbols, nil
+}
+
+// tokenizeSQL splits SQL source into tokens, dropping whitespace and
+// comments.
+func tokenizeSQL(src []byte) []sqlToken {
+	var toks []sqlToken
+	line, lineStart := 1, 0
+	// skip moves i past n bytes, keeping track of lines
+	skip := func(i int, n int) int {
+		end := min(i+n, len(src))
+		for ; i < end; i++ {
+			if src[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		tok := sqlToken{line: line, column: i - lineStart}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			i = skip(i, 1)
+			continue
+
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+			continue
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			// Block comments nest in PostgreSQL
+			nesting := 0
+			for i < len(src) {
+				if bytes.HasPrefix(src[i:], []byte("/*")) {
+					nesting++
+					i += 2
+				} else if bytes.HasPrefix(src[i:], []byte("*/")) {
+					nesting--
+					i += 2
+					if nesting == 0 {
+						break
+					}
+				} else {
+					i = skip(i, 1)
+				}
+			}
+			continue
+
+		case c == '\'':
+			// E'...' strings allow backslash escapes
+			prev := sqlToken{}
+			if len(toks) > 0 {
+				prev = toks[len(toks)-1]
+			}
+			escapes := prev.line == tok.line && prev.column+1 == tok.column && strings.EqualFold(prev.text, "E")
+			i++
+			for i < len(src) {
+				if escapes && src[i] == '\\' {
+					i = skip(i, 2)
+				} else if src[i] == '\'' {
+					i++
+					if i < len(src) && src[i] == '\'' {
+						i++ // Doubled quote
+						continue
+					}
+					break
+				} else {
+					i = skip(i, 1)
+				}
+			}
+			tok.kind = sqlString
+
+		case c == '"' || c == '`':
+			var name strings.Builder
+			i++
+			for i < len(src) {
+				if src[i] == c {
+					if i+1 < len(src) && src[i+1] == c {
+						name.WriteByte(c) // Doubled quote
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				name.WriteByte(src[i])
+				i = skip(i, 1)
+			}
+			tok.kind = sqlQuoted
+			tok.text = name.String()
+
+		case c == '[' && bracketedSQLName(src[i:]) > 0:
+			n := bracketedSQLName(src[i:])
+			tok.kind = sqlQuoted
+			tok.text = string(src[i+1 : i+n-1])
+			i += n
+
+		case c == '$' && dollarQuoteTag(src[i:]) != nil:
+			tag := dollarQuoteTag(src[i:])
+			end := bytes.Index(src[i+len(tag):], tag)
+			if end < 0 {
+				end = len(src) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			i = skip(i, len(tag)+end)
+			tok.kind = sqlString
+
+		case isSQLWordByte(c):
+			for i < len(src) && isSQLWordByte(src[i]) {
+				i++
+			}
+			tok.kind = sqlWord
+
+		default:
+			i++
+			tok.kind = sqlPunct
+		}
+
+		if tok.text == "" {
+			tok.text = string(src[start:i])
+		}
+		toks = append(toks, tok)
+	}
+	return toks
+}
+
+// bracketedSQLName returns the length of a SQL Server [name] at the start
+// of src, or 0 if src does not start with one.
+func bracketedSQLName(src []byte) int {
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case ']':
+			if i == 1 {
+				return 0
+			}
+			return i + 1
+		case '\n', '[':
+			return 0
+		}
+	}
+	return 0
+}
+
+// dollarQuoteTag returns the opening tag of a PostgreSQL dollar-quoted
+// string at the start of src, such as $$ or $body$, or nil.
+func dollarQuoteTag(src []byte) []byte {
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '$':
+			return src[:i+1]
+		case isDigit(c) && i == 1:
+			return nil // A positional parameter such as $1
+		case !isSQLWordByte(c) || c == '$':
+			return nil
+		}
+	}
+	return nil
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c >= 0x80
+}
@@ -0,0 +1,79 @@
+package finder
+
+import (
+	"testing"
+)
+
+func TestExtractSQLSymbols(t *testing.T) {
+	src := `-- CREATE TABLE commented_out (id int);
+/* CREATE TABLE also_commented /* nested */ (id int); */
+CREATE SCHEMA IF NOT EXISTS billing;
+CREATE SCHEMA AUTHORIZATION admin;
+
+CREATE TABLE IF NOT EXISTS billing.invoices (
+	id BIGSERIAL PRIMARY KEY,
+	note TEXT DEFAULT 'CREATE TABLE not_a_table (x int)',
+	tags TEXT[]
+);
+create temporary table scratch (id int);
+CREATE TABLE "Quoted Name" (id int);
+CREATE TABLE [dbo].[Orders] (id int);
+CREATE TABLE ` + "`shop`.`items`" + ` (id int);
+
+CREATE OR REPLACE VIEW billing.open_invoices AS SELECT * FROM billing.invoices;
+CREATE MATERIALIZED VIEW IF NOT EXISTS monthly_totals AS SELECT 1;
+CREATE ALGORITHM = MERGE DEFINER = ` + "`root`@`localhost`" + ` SQL SECURITY DEFINER VIEW recent AS SELECT 1;
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS invoices_number_idx ON billing.invoices (number);
+CREATE INDEX ON billing.invoices (created_at);
+
+CREATE OR REPLACE FUNCTION billing.touch() RETURNS trigger AS $body$
+BEGIN
+	EXECUTE 'CREATE TABLE dynamic (id int)';
+	CREATE TEMP TABLE inside_body (id int);
+	RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+
+CREATE PROCEDURE archive(cutoff date) LANGUAGE sql AS $$ DELETE FROM scratch $$;
+CREATE CONSTRAINT TRIGGER invoices_touch AFTER UPDATE ON billing.invoices
+	FOR EACH ROW EXECUTE FUNCTION billing.touch();
+CREATE TYPE billing.status AS ENUM ('open', 'paid');
+CREATE SEQUENCE billing.invoice_numbers START 1000;
+CREATE DOMAIN positive_amount AS numeric CHECK (VALUE > 0);
+`
+
+	symbols, err := extractSQLSymbols([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertSymbols(t, symbols, []symbolSummary{
+		{"billing", "schema", "", 3},
+		{"invoices", "table", "billing", 6},
+		{"scratch", "table", "", 11},
+		{"Quoted Name", "table", "", 12},
+		{"Orders", "table", "dbo", 13},
+		{"items", "table", "shop", 14},
+		{"open_invoices", "view", "billing", 16},
+		{"monthly_totals", "materialized_view", "", 17},
+		{"recent", "view", "", 18},
+		{"invoices_number_idx", "index", "", 20},
+		{"touch", "function", "billing", 23},
+		{"archive", "procedure", "", 31},
+		{"invoices_touch", "trigger", "", 32},
+		{"status", "type", "billing", 34},
+		{"invoice_numbers", "sequence", "billing", 35},
+		{"positive_amount", "domain", "", 36},
+	})
+
+	if symbols[1].Column != 35 {
+		t.Errorf("expected invoices at column 35, got %d", symbols[1].Column)
+	}
+	if symbols[3].Column != 14 {
+		t.Errorf("expected quoted name at column 14, got %d", symbols[3].Column)
+	}
+	if symbols[1].QualifiedName() != "billing.invoices" {
+		t.Errorf("expected qualified name billing.invoices, got %s", symbols[1].QualifiedName())
+	}
+}
@@ -0,0 +1,189 @@
+package finder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TagFormat is the format of a tag file written by WriteTags.
+type TagFormat int
+
+const (
+	// CtagsFormat is the extended tags format of universal-ctags and Vim.
+	CtagsFormat TagFormat = iota
+	// EtagsFormat is the TAGS format of Emacs.
+	EtagsFormat
+)
+
+// ctagsHeader starts a tags file in CtagsFormat.
+const ctagsHeader = "!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;\" to lines/\n" +
+	"!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n" +
+	"!_TAG_PROGRAM_NAME\tvtk\t//\n"
+
+// WriteTags writes a tag file to path with the symbols of the code files
+// under dir, respecting .gitignore rules. File names in the tag file are
+// relative to its directory.
+//
+// Symbols come from the symbol index, which WriteTags creates or updates,
+// so regenerating tags only parses the files that changed. The tag file is
+// replaced atomically, so an editor never reads a partial one. The returned
+// stats count the files and symbols in the tag file.
+func WriteTags(dir string, path string, format TagFormat, opts Options) (*IndexStats, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	tagsDir := filepath.Dir(absPath)
+
+	// Format each file's tags as its symbols arrive
+	var mu sync.Mutex
+	entries := make(map[string][]string)
+	tagged := 0
+	var readErr error
+	stats, err := loadSymbolIndex(dir).update(dir, opts, func(file string, symbols []Symbol) {
+		name, err := tagFileName(tagsDir, file)
+		if err != nil {
+			return
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			mu.Lock()
+			readErr = fmt.Errorf("failed to read %s: %w", file, err)
+			mu.Unlock()
+			return
+		}
+
+		var lines []string
+		if format == EtagsFormat {
+			lines = []string{etagsSection(name, content, symbols)}
+		} else {
+			lines = ctagsLines(name, content, symbols)
+		}
+		mu.Lock()
+		entries[name] = lines
+		tagged += len(symbols)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var output strings.Builder
+	if format == EtagsFormat {
+		// Sections in file name order
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			output.WriteString(entries[name][0])
+		}
+	} else {
+		// Tags sorted by name, as the header promises
+		var lines []string
+		for _, fileLines := range entries {
+			lines = append(lines, fileLines...)
+		}
+		sort.Strings(lines)
+		output.WriteString(ctagsHeader)
+		for _, line := range lines {
+			output.WriteString(line)
+			output.WriteByte('\n')
+		}
+	}
+
+	if err := writeFileAtomic(absPath, []byte(output.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	stats.Files = len(entries)
+	stats.Symbols = tagged
+	return stats, nil
+}
+
+// tagFileName returns the slash-separated name of file relative to the
+// directory of the tag file.
+func tagFileName(tagsDir string, file string) (string, error) {
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(tagsDir, absFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// sourceLines splits content into lines without their line endings, along
+// with the byte offset at which each line starts.
+func sourceLines(content []byte) (lines []string, offsets []int) {
+	offset := 0
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if line == "" {
+			break
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+		offsets = append(offsets, offset)
+		offset += len(line)
+	}
+	return lines, offsets
+}
+
+// etagsSection returns the section of an Emacs TAGS file for a file. Each
+// tag names the symbol explicitly after the text of its line up to the end
+// of the name, which Emacs searches for near the recorded line and offset.
+func etagsSection(name string, content []byte, symbols []Symbol) string {
+	lines, offsets := sourceLines(content)
+
+	var body strings.Builder
+	for _, symbol := range symbols {
+		if symbol.Line < 1 || symbol.Line > len(lines) {
+			continue
+		}
+		text := lines[symbol.Line-1]
+		prefix := text[:min(symbol.Column+len(symbol.Name), len(text))]
+		prefix = strings.ReplaceAll(prefix, "\x7f", "")
+		fmt.Fprintf(&body, "%s\x7f%s\x01%d,%d\n", prefix, symbol.Name, symbol.Line, offsets[symbol.Line-1])
+	}
+	return fmt.Sprintf("\x0c\n%s,%d\n%s", name, body.Len(), body.String())
+}
+
+// ctagsLines returns the tag lines for the symbols of a file in the
+// extended ctags format. Each tag is located by a search pattern for its
+// whole line and carries its kind, line number and, for members, the scope
+// it belongs to, named by the scope's kind as universal-ctags does.
+func ctagsLines(name string, content []byte, symbols []Symbol) []string {
+	lines, _ := sourceLines(content)
+
+	// Kinds of the symbols that may be scopes, such as classes
+	scopeKinds := make(map[string]string)
+	for _, symbol := range symbols {
+		scopeKinds[symbol.QualifiedName()] = symbol.Kind
+	}
+
+	var tags []string
+	for _, symbol := range symbols {
+		if symbol.Line < 1 || symbol.Line > len(lines) {
+			continue
+		}
+		pattern := strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(lines[symbol.Line-1])
+		tag := fmt.Sprintf("%s\t%s\t/^%s$/;\"\tkind:%s\tline:%d", symbol.Name, name, pattern, symbol.Kind, symbol.Line)
+		if symbol.Receiver != "" {
+			scopeKind := scopeKinds[symbol.Receiver]
+			if scopeKind == "" {
+				scopeKind = "class"
+			}
+			tag += fmt.Sprintf("\t%s:%s", scopeKind, symbol.Receiver)
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
@@ -0,0 +1,92 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTagsFixture creates a small tree with a Go and a Python file and an
+// ignored file.
+func writeTagsFixture(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, "pkg"), 0755)
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, filepath.Join(tempDir, "main.go"), "package main\n\ntype Server struct{}\n\nfunc (s *Server) Serve() {}\n", old)
+	writeAged(t, filepath.Join(tempDir, "pkg", "a.py"), "class A:\n    def f(self):\n        return '/path\\\\'\n\nRATE = 1  # a/b\n", old)
+	writeAged(t, filepath.Join(tempDir, ".gitignore"), "gen.go\n", old)
+	writeAged(t, filepath.Join(tempDir, "gen.go"), "package main\n\nfunc Generated() {}\n", old)
+	return tempDir
+}
+
+func TestWriteTags_Ctags(t *testing.T) {
+	tempDir := writeTagsFixture(t)
+	path := filepath.Join(tempDir, "tags")
+
+	stats, err := WriteTags(tempDir, path, CtagsFormat, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Files != 2 || stats.Parsed != 2 {
+		t.Errorf("unexpected stats: %+v", *stats)
+	}
+
+	content, _ := os.ReadFile(path)
+	want := ctagsHeader +
+		"A\tpkg/a.py\t/^class A:$/;\"\tkind:class\tline:1\n" +
+		"RATE\tpkg/a.py\t/^RATE = 1  # a\\/b$/;\"\tkind:variable\tline:5\n" +
+		"Serve\tmain.go\t/^func (s *Server) Serve() {}$/;\"\tkind:method\tline:5\tstruct:Server\n" +
+		"Server\tmain.go\t/^type Server struct{}$/;\"\tkind:struct\tline:3\n" +
+		"f\tpkg/a.py\t/^    def f(self):$/;\"\tkind:method\tline:2\tclass:A\n"
+	if string(content) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, content)
+	}
+
+	// Regenerating parses nothing again
+	stats, err = WriteTags(tempDir, path, CtagsFormat, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Parsed != 0 {
+		t.Errorf("expected no files to be parsed, got %+v", *stats)
+	}
+}
+
+func TestWriteTags_Etags(t *testing.T) {
+	tempDir := writeTagsFixture(t)
+	path := filepath.Join(tempDir, "TAGS")
+
+	if _, err := WriteTags(tempDir, path, EtagsFormat, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mainBody := "type Server\x7fServer\x013,14\n" +
+		"func (s *Server) Serve\x7fServe\x015,36\n"
+	pyBody := "class A\x7fA\x011,0\n" +
+		"    def f\x7ff\x012,9\n" +
+		"RATE\x7fRATE\x015,52\n"
+	want := "\x0c\nmain.go," + strconv.Itoa(len(mainBody)) + "\n" + mainBody +
+		"\x0c\npkg/a.py," + strconv.Itoa(len(pyBody)) + "\n" + pyBody
+
+	content, _ := os.ReadFile(path)
+	if string(content) != want {
+		t.Errorf("expected:\n%q\ngot:\n%q", want, content)
+	}
+}
+
+func TestWriteTags_RelativeToTagFile(t *testing.T) {
+	tempDir := writeTagsFixture(t)
+	path := filepath.Join(tempDir, "pkg", "tags")
+
+	if _, err := WriteTags(filepath.Join(tempDir, "pkg"), path, CtagsFormat, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "A\ta.py\t") || strings.Contains(string(content), "main.go") {
+		t.Errorf("expected only pkg's files, relative to the tag file:\n%s", content)
+	}
+}
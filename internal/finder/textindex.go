@@ -0,0 +1,213 @@
+package finder
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// textIndexVersion is bumped whenever the text index format changes.
+const textIndexVersion = 1
+
+// textIndexName is the file holding the text index in the index directory
+// of the state directory. It is encoded with gob rather than JSON since it
+// holds a set of trigrams for every file of the tree.
+const textIndexName = "trigrams.gob"
+
+// textIndex caches the trigrams of the text files of a tree so that text
+// searches only read the files that may contain a match of their pattern,
+// in the spirit of Google Code Search. Entries are keyed and kept up to
+// date like those of the symbol index. It is safe for concurrent use.
+type textIndex struct {
+	root string // directory the keys are relative to
+	path string // file the index is stored in
+
+	mu     sync.Mutex
+	data   textIndexData
+	parsed int
+	dirty  bool
+}
+
+// textIndexData is the on-disk form of a text index.
+type textIndexData struct {
+	Version int
+	Saved   time.Time
+	Files   map[string]*textIndexedFile
+}
+
+// textIndexedFile is the text index entry of a single file.
+type textIndexedFile struct {
+	Stamp    fileStamp
+	Binary   bool
+	Trigrams []uint32 // sorted
+}
+
+// BuildTextIndex creates or updates the trigram index for the text files
+// under dir, reading only the files that are new or changed since the last
+// update and dropping files that no longer exist. Later text searches of
+// dir or any directory below it use the index to skip files that cannot
+// match. Files are read on a pool of opts.Workers goroutines.
+func BuildTextIndex(dir string, opts Options) (*IndexStats, error) {
+	// Check if directory exists
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("directory does not exist: %s", dir)
+	}
+
+	index := loadTextIndex(dir)
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+
+	err := streamFiles(context.Background(), Options{Workers: opts.Workers}, walkTextFiles(dir, opts.Cache, nil), func(path string) ([]Result, error) {
+		if _, err := index.entry(path); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		seen[index.key(path)] = true
+		mu.Unlock()
+		return nil, nil
+	}, func(Result) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the files under dir that the walk no longer found
+	stats := &IndexStats{Parsed: index.parsed}
+	for _, key := range staleKeys(index.data.Files, index.key(dir), seen) {
+		delete(index.data.Files, key)
+		stats.Removed++
+	}
+	stats.Files = len(index.data.Files)
+
+	// Save even an unchanged index so that later searches find it
+	index.dirty = true
+	if err := index.save(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// openTextIndex returns the text index covering dir, or nil if none was
+// built.
+func openTextIndex(dir string) *textIndex {
+	index := loadTextIndex(dir)
+	if _, err := os.Stat(index.path); err != nil {
+		return nil
+	}
+	return index
+}
+
+// loadTextIndex reads the text index covering dir. A missing, unreadable or
+// outdated index yields an empty one to be rebuilt.
+func loadTextIndex(dir string) *textIndex {
+	root := stateRoot(dir)
+	index := &textIndex{
+		root: root,
+		path: filepath.Join(root, stateDirName, "index", textIndexName),
+	}
+
+	if file, err := os.Open(index.path); err == nil {
+		if gob.NewDecoder(file).Decode(&index.data) != nil || index.data.Version != textIndexVersion {
+			index.data = textIndexData{}
+		}
+		file.Close()
+	}
+	if index.data.Files == nil {
+		index.data = textIndexData{Version: textIndexVersion, Files: make(map[string]*textIndexedFile)}
+	}
+	return index
+}
+
+// key returns the index key of path.
+func (idx *textIndex) key(path string) string {
+	return indexKey(idx.root, path)
+}
+
+// entry returns the index entry of the file at path, reading the file again
+// if it changed since it was indexed.
+func (idx *textIndex) entry(path string) (*textIndexedFile, error) {
+	key := idx.key(path)
+	idx.mu.Lock()
+	entry := idx.data.Files[key]
+	saved := idx.data.Saved
+	idx.mu.Unlock()
+
+	var old *fileStamp
+	if entry != nil {
+		old = &entry.Stamp
+	}
+	stamp, content, changed, err := refreshStamp(path, old, saved)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		// A touched but unchanged file only needs its entry refreshed
+		if stamp != entry.Stamp {
+			entry = &textIndexedFile{Stamp: stamp, Binary: entry.Binary, Trigrams: entry.Trigrams}
+			idx.store(key, entry, false)
+		}
+		return entry, nil
+	}
+
+	entry = &textIndexedFile{Stamp: stamp}
+	if isBinaryContent(content) {
+		entry.Binary = true
+	} else {
+		entry.Trigrams = contentTrigrams(content)
+	}
+	idx.store(key, entry, true)
+	return entry, nil
+}
+
+// mayMatch reports whether the file at path may contain a match of query.
+// Binary files never match, as text searches skip them.
+func (idx *textIndex) mayMatch(path string, query *trigramQuery) bool {
+	entry, err := idx.entry(path)
+	if err != nil {
+		return true // Let the search report or skip the file
+	}
+	if entry.Binary {
+		return false
+	}
+	return query.matches(func(t uint32) bool {
+		i := sort.Search(len(entry.Trigrams), func(i int) bool { return entry.Trigrams[i] >= t })
+		return i < len(entry.Trigrams) && entry.Trigrams[i] == t
+	})
+}
+
+// store records the entry of the file with the given key.
+func (idx *textIndex) store(key string, entry *textIndexedFile, parsed bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.data.Files[key] = entry
+	idx.dirty = true
+	if parsed {
+		idx.parsed++
+	}
+}
+
+// save writes the index back to disk if it changed.
+func (idx *textIndex) save() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.dirty {
+		return nil
+	}
+
+	idx.data.Saved = time.Now()
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(idx.data); err != nil {
+		return fmt.Errorf("failed to encode text index: %w", err)
+	}
+	if err := writeIndexFile(idx.root, textIndexName, data.Bytes()); err != nil {
+		return fmt.Errorf("failed to write text index: %w", err)
+	}
+
+	idx.dirty = false
+	return nil
+}
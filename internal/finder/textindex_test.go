@@ -0,0 +1,145 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildTextIndex(t *testing.T) {
+	tempDir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, filepath.Join(tempDir, "a.txt"), "the quick brown fox\n", old)
+	writeAged(t, filepath.Join(tempDir, "b.txt"), "jumps over the lazy dog\n", old)
+	writeAged(t, filepath.Join(tempDir, "c.bin"), "fox\x00binary", old)
+
+	patterns := []string{`fox`, `(?i)LAZY`, `the`, `qu.ck|dog`, `missing`}
+	unindexed := make(map[string]string)
+	for _, pattern := range patterns {
+		results, err := Find(tempDir, pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		unindexed[pattern] = FormatEmacsOutput(results)
+	}
+
+	stats, err := BuildTextIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *stats != (IndexStats{Files: 3, Parsed: 3}) {
+		t.Errorf("unexpected stats: %+v", *stats)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, stateDirName, "index", textIndexName)); err != nil {
+		t.Fatalf("expected the index to be saved: %v", err)
+	}
+
+	// Indexed searches find exactly what full scans find
+	for _, pattern := range patterns {
+		results, err := Find(tempDir, pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := FormatEmacsOutput(results); got != unindexed[pattern] {
+			t.Errorf("pattern %q: indexed search returned:\n%s\nexpected:\n%s", pattern, got, unindexed[pattern])
+		}
+	}
+
+	// Deleted files are dropped and unchanged ones are not read again
+	os.Remove(filepath.Join(tempDir, "b.txt"))
+	stats, err = BuildTextIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *stats != (IndexStats{Files: 2, Removed: 1}) {
+		t.Errorf("unexpected stats after removal: %+v", *stats)
+	}
+}
+
+func TestTextIndex_SkipsRuledOutFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "a.txt")
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, path, "alpha\n", old)
+
+	if _, err := BuildTextIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// With the same size and modification time the index is trusted, so
+	// the file is not read for a pattern it did not contain
+	writeAged(t, path, "gamma\n", old)
+	results, err := Find(tempDir, `gamma`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected the index to rule out the file, got %+v", results)
+	}
+
+	// A changed size is noticed and the entry updated
+	writeAged(t, path, "gamma delta\n", old)
+	results, err = Find(tempDir, `gamma`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected the changed file to be searched, got %+v", results)
+	}
+	stats, err := BuildTextIndex(tempDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Parsed != 0 {
+		t.Errorf("expected the search to have updated the index, got %+v", *stats)
+	}
+}
+
+func TestTextIndex_CaseFolds(t *testing.T) {
+	tempDir := t.TempDir()
+	writeAged(t, filepath.Join(tempDir, "a.txt"), "DAR\u212aNE\u017fS\n", time.Now().Add(-time.Hour))
+
+	// The index never changes the results of a search
+	for _, build := range []bool{false, true} {
+		if build {
+			if _, err := BuildTextIndex(tempDir, Options{}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		results, err := Find(tempDir, `(?i)darkness`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("index built %v: expected a match, got %+v", build, results)
+		}
+	}
+}
+
+func TestTextIndex_Subdirectory(t *testing.T) {
+	// Outside a repository the index of a directory covers those below it
+	tempDir := t.TempDir()
+	sub := filepath.Join(tempDir, "sub")
+	os.Mkdir(sub, 0755)
+	path := filepath.Join(sub, "a.txt")
+	old := time.Now().Add(-time.Hour)
+	writeAged(t, path, "alpha\n", old)
+
+	if _, err := BuildTextIndex(tempDir, Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if openTextIndex(sub) == nil {
+		t.Fatal("expected the index to cover the subdirectory")
+	}
+
+	// The index rules out a file whose unnoticed edit would match
+	writeAged(t, path, "gamma\n", old)
+	results, err := Find(sub, `gamma`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected the index to rule out the file, got %+v", results)
+	}
+}
@@ -0,0 +1,354 @@
+package finder
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxExactStrings bounds the set of strings a regular expression is known
+// to match exactly before the analysis falls back to a trigram query.
+const maxExactStrings = 16
+
+// maxClassRunes is the largest character class expanded into the strings
+// it matches.
+const maxClassRunes = 8
+
+// trigramOp is the operation of a trigramQuery.
+type trigramOp int
+
+const (
+	trigramAll trigramOp = iota // any file may match
+	trigramAnd                  // all trigrams and subqueries must match
+	trigramOr                   // at least one trigram or subquery must match
+)
+
+// trigramQuery is a boolean query over the trigrams of a file that every
+// file matching a regular expression satisfies. Trigrams are taken from
+// content with ASCII letters lowercased, so that one index serves case
+// sensitive and insensitive searches.
+type trigramQuery struct {
+	op       trigramOp
+	trigrams []uint32
+	sub      []*trigramQuery
+}
+
+// matches reports whether a file whose trigram set is tested by has may
+// match the query.
+func (q *trigramQuery) matches(has func(uint32) bool) bool {
+	switch q.op {
+	case trigramAnd:
+		for _, t := range q.trigrams {
+			if !has(t) {
+				return false
+			}
+		}
+		for _, sub := range q.sub {
+			if !sub.matches(has) {
+				return false
+			}
+		}
+		return true
+	case trigramOr:
+		for _, t := range q.trigrams {
+			if has(t) {
+				return true
+			}
+		}
+		for _, sub := range q.sub {
+			if sub.matches(has) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// andQuery returns a query matching the files that match both a and b.
+func andQuery(a *trigramQuery, b *trigramQuery) *trigramQuery {
+	switch {
+	case a.op == trigramAll:
+		return b
+	case b.op == trigramAll:
+		return a
+	}
+	q := &trigramQuery{op: trigramAnd}
+	for _, part := range []*trigramQuery{a, b} {
+		if part.op == trigramAnd {
+			q.trigrams = append(q.trigrams, part.trigrams...)
+			q.sub = append(q.sub, part.sub...)
+		} else {
+			q.sub = append(q.sub, part)
+		}
+	}
+	return q
+}
+
+// orQuery returns a query matching the files that match a or b.
+func orQuery(a *trigramQuery, b *trigramQuery) *trigramQuery {
+	if a.op == trigramAll || b.op == trigramAll {
+		return &trigramQuery{op: trigramAll}
+	}
+	q := &trigramQuery{op: trigramOr}
+	for _, part := range []*trigramQuery{a, b} {
+		if part.op == trigramOr {
+			q.trigrams = append(q.trigrams, part.trigrams...)
+			q.sub = append(q.sub, part.sub...)
+		} else {
+			q.sub = append(q.sub, part)
+		}
+	}
+	return q
+}
+
+// regexpInfo is what the analysis knows about a regular expression: either
+// the exact set of strings it matches, or a query its matches satisfy.
+type regexpInfo struct {
+	exact []string // nil when the strings are unknown
+	query *trigramQuery
+}
+
+// anyInfo is the analysis of an expression that may match anything.
+func anyInfo() regexpInfo {
+	return regexpInfo{query: &trigramQuery{op: trigramAll}}
+}
+
+// toQuery returns the query satisfied by the matches of info.
+func (info regexpInfo) toQuery() *trigramQuery {
+	if info.exact == nil {
+		return info.query
+	}
+	if len(info.exact) == 0 {
+		return &trigramQuery{op: trigramAll}
+	}
+	var q *trigramQuery
+	for _, s := range info.exact {
+		if len(s) < 3 {
+			return &trigramQuery{op: trigramAll} // Too short to constrain
+		}
+		and := &trigramQuery{op: trigramAnd, trigrams: stringTrigrams(s)}
+		if q == nil {
+			q = and
+		} else {
+			q = orQuery(q, and)
+		}
+	}
+	return q
+}
+
+// regexpTrigramQuery returns a query that every file containing a match of
+// the regular expression pattern satisfies. Patterns it cannot constrain,
+// such as ".*", yield a query that matches every file.
+func regexpTrigramQuery(pattern string) *trigramQuery {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return &trigramQuery{op: trigramAll}
+	}
+	return analyzeRegexp(re.Simplify()).toQuery()
+}
+
+// analyzeRegexp computes the regexpInfo of a parsed regular expression.
+func analyzeRegexp(re *syntax.Regexp) regexpInfo {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return regexpInfo{exact: []string{""}}
+
+	case syntax.OpLiteral:
+		// Case-insensitive runes with a non-ASCII fold, like k and the
+		// Kelvin sign, have folds the index does not lowercase, so they
+		// match anything
+		parts := make([]regexpInfo, len(re.Rune))
+		for i, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && hasNonASCIIFold(r) {
+				parts[i] = anyInfo()
+			} else {
+				parts[i] = regexpInfo{exact: []string{lowerASCII(string(r))}}
+			}
+		}
+		return concatInfo(parts)
+
+	case syntax.OpCharClass:
+		return classInfo(re.Rune)
+
+	case syntax.OpCapture:
+		return analyzeRegexp(re.Sub[0])
+
+	case syntax.OpConcat:
+		parts := make([]regexpInfo, len(re.Sub))
+		for i, sub := range re.Sub {
+			parts[i] = analyzeRegexp(sub)
+		}
+		return concatInfo(parts)
+
+	case syntax.OpAlternate:
+		var exact []string
+		var q *trigramQuery
+		allExact := true
+		for _, sub := range re.Sub {
+			info := analyzeRegexp(sub)
+			if info.exact == nil || len(exact)+len(info.exact) > maxExactStrings {
+				allExact = false
+			}
+			exact = append(exact, info.exact...)
+			if q == nil {
+				q = info.toQuery()
+			} else {
+				q = orQuery(q, info.toQuery())
+			}
+		}
+		if allExact {
+			return regexpInfo{exact: dedupeStrings(exact)}
+		}
+		return regexpInfo{query: q}
+
+	case syntax.OpQuest:
+		info := analyzeRegexp(re.Sub[0])
+		if info.exact != nil && len(info.exact) < maxExactStrings {
+			return regexpInfo{exact: dedupeStrings(append([]string{""}, info.exact...))}
+		}
+		return anyInfo()
+
+	case syntax.OpPlus:
+		return regexpInfo{query: analyzeRegexp(re.Sub[0]).toQuery()}
+
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return anyInfo()
+		}
+		return regexpInfo{query: analyzeRegexp(re.Sub[0]).toQuery()}
+
+	default:
+		// Any character, stars and anything unknown
+		return anyInfo()
+	}
+}
+
+// hasNonASCIIFold reports whether r case-folds to other runes and any of
+// them, or r itself, is outside ASCII.
+func hasNonASCIIFold(r rune) bool {
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if r >= utf8.RuneSelf || f >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+// concatInfo computes the regexpInfo of a concatenation. It keeps the exact
+// strings of consecutive parts as long as their cross product stays small,
+// and otherwise requires each run of exact parts and each other part to be
+// satisfied separately.
+func concatInfo(parts []regexpInfo) regexpInfo {
+	current := []string{""}
+	q := &trigramQuery{op: trigramAll}
+	allExact := true
+
+	for _, part := range parts {
+		if part.exact != nil && len(current)*len(part.exact) <= maxExactStrings {
+			var next []string
+			for _, prefix := range current {
+				for _, s := range part.exact {
+					next = append(next, prefix+s)
+				}
+			}
+			current = dedupeStrings(next)
+			continue
+		}
+
+		allExact = false
+		q = andQuery(q, regexpInfo{exact: current}.toQuery())
+		if part.exact != nil {
+			// Too many combinations: start over from this part's strings
+			current = part.exact
+		} else {
+			q = andQuery(q, part.query)
+			current = []string{""}
+		}
+	}
+
+	if allExact {
+		return regexpInfo{exact: current}
+	}
+	return regexpInfo{query: andQuery(q, regexpInfo{exact: current}.toQuery())}
+}
+
+// classInfo computes the regexpInfo of a character class given as pairs of
+// rune ranges. Small classes match an exact set of one-rune strings.
+func classInfo(ranges []rune) regexpInfo {
+	count := 0
+	for i := 0; i+1 < len(ranges); i += 2 {
+		count += int(ranges[i+1]-ranges[i]) + 1
+		if count > maxClassRunes {
+			return anyInfo()
+		}
+	}
+	var exact []string
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1]; r++ {
+			exact = append(exact, lowerASCII(string(r)))
+		}
+	}
+	if len(exact) == 0 {
+		return anyInfo()
+	}
+	return regexpInfo{exact: dedupeStrings(exact)}
+}
+
+// stringTrigrams returns the trigrams of s, which must already have its
+// ASCII letters lowercased.
+func stringTrigrams(s string) []uint32 {
+	var trigrams []uint32
+	for i := 0; i+3 <= len(s); i++ {
+		trigrams = append(trigrams, uint32(s[i])<<16|uint32(s[i+1])<<8|uint32(s[i+2]))
+	}
+	return trigrams
+}
+
+// contentTrigrams returns the sorted set of trigrams of content, with ASCII
+// letters lowercased.
+func contentTrigrams(content []byte) []uint32 {
+	set := make(map[uint32]struct{})
+	var t uint32
+	for i, c := range content {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		t = (t<<8 | uint32(c)) & 0xFFFFFF
+		if i >= 2 {
+			set[t] = struct{}{}
+		}
+	}
+	trigrams := make([]uint32, 0, len(set))
+	for t := range set {
+		trigrams = append(trigrams, t)
+	}
+	sort.Slice(trigrams, func(i, j int) bool { return trigrams[i] < trigrams[j] })
+	return trigrams
+}
+
+// lowerASCII lowercases the ASCII letters of s, leaving other runes alone.
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// dedupeStrings sorts strs and removes duplicates.
+func dedupeStrings(strs []string) []string {
+	sort.Strings(strs)
+	out := strs[:0]
+	for i, s := range strs {
+		if i == 0 || s != strs[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package finder
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// queryMatchesContent evaluates query against the trigrams of content.
+func queryMatchesContent(query *trigramQuery, content string) bool {
+	trigrams := contentTrigrams([]byte(content))
+	return query.matches(func(t uint32) bool {
+		i := sort.Search(len(trigrams), func(i int) bool { return trigrams[i] >= t })
+		return i < len(trigrams) && trigrams[i] == t
+	})
+}
+
+func TestRegexpTrigramQuery(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string // contents the query must accept
+		rejects []string // contents the query should rule out
+	}{
+		{
+			pattern: `hello`,
+			matches: []string{"say hello world", "HELLO"},
+			rejects: []string{"help", "hell o"},
+		},
+		{
+			pattern: `(?i)Hello`,
+			matches: []string{"hElLo there"},
+			rejects: []string{"hallo"},
+		},
+		{
+			pattern: `func (Find|Replace)Stream`,
+			matches: []string{"func FindStream(", "func ReplaceStream("},
+			rejects: []string{"func GlobStream(", "FindStream"},
+		},
+		{
+			pattern: `colou?r`,
+			matches: []string{"color", "colour"},
+			rejects: []string{"colr"},
+		},
+		{
+			pattern: `log[Ff]ile\d+`,
+			matches: []string{"logFile12", "logfile3"},
+			rejects: []string{"log file 3"},
+		},
+		{
+			pattern: `error.*failed`,
+			matches: []string{"error: it failed"},
+			rejects: []string{"error only", "failed only"},
+		},
+		{
+			pattern: `(abc)+def`,
+			matches: []string{"abcabcdef"},
+			rejects: []string{"abdef"},
+		},
+		{
+			pattern: `^\s*import`,
+			matches: []string{"  import os"},
+			rejects: []string{"export"},
+		},
+		{
+			// Nothing to go on: every file may match
+			pattern: `.*`,
+			matches: []string{"", "anything"},
+		},
+		{
+			pattern: `[a-z]+`,
+			matches: []string{"x"},
+		},
+		{
+			pattern: `ab`,
+			matches: []string{"ab", "xaby"},
+		},
+		{
+			pattern: `(?i)straße`,
+			matches: []string{"STRASSE", "Straße", "STRAẞE"},
+		},
+		{
+			// k and s fold to the Kelvin sign and the long s
+			pattern: `(?i)darkness`,
+			matches: []string{"DAR\u212aNESS", "dark\u017fess", "Darkness"},
+			rejects: []string{"lightness"},
+		},
+		{
+			// Runes without folds still constrain the query
+			pattern: `(?i)日本語`,
+			matches: []string{"日本語"},
+			rejects: []string{"中文"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			query := regexpTrigramQuery(tt.pattern)
+			re := regexp.MustCompile(tt.pattern)
+			for _, content := range tt.matches {
+				if re.MatchString(content) && !queryMatchesContent(query, content) {
+					t.Errorf("query for %q rules out matching content %q", tt.pattern, content)
+				}
+			}
+			for _, content := range tt.rejects {
+				if queryMatchesContent(query, content) {
+					t.Errorf("query for %q accepts %q", tt.pattern, content)
+				}
+			}
+		})
+	}
+}
+
+func TestContentTrigrams(t *testing.T) {
+	got := contentTrigrams([]byte("AbCab"))
+	want := []uint32{
+		uint32('a')<<16 | uint32('b')<<8 | uint32('c'),
+		uint32('b')<<16 | uint32('c')<<8 | uint32('a'),
+		uint32('c')<<16 | uint32('a')<<8 | uint32('b'),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d trigrams, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trigram %d: expected %06x, got %06x", i, want[i], got[i])
+		}
+	}
+
+	if len(contentTrigrams([]byte("ab"))) != 0 {
+		t.Error("expected no trigrams for content shorter than three bytes")
+	}
+}
@@ -0,0 +1,138 @@
+package finder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// builtinFileTypes maps the names of the file types known without any
+// configuration to the globs matching their file names, after ripgrep's.
+var builtinFileTypes = map[string][]string{
+	"c":      {"*.c", "*.h"},
+	"cpp":    {"*.cpp", "*.cc", "*.cxx", "*.c++", "*.hpp", "*.hh", "*.hxx", "*.h++", "*.h"},
+	"cs":     {"*.cs"},
+	"css":    {"*.css", "*.scss", "*.sass", "*.less"},
+	"csv":    {"*.csv", "*.tsv"},
+	"docker": {"Dockerfile", "Dockerfile.*", "*.dockerfile", "*.Dockerfile"},
+	"go":     {"*.go"},
+	"html":   {"*.html", "*.htm", "*.xhtml"},
+	"java":   {"*.java"},
+	"js":     {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":   {"*.json", "*.jsonl", "*.geojson"},
+	"kotlin": {"*.kt", "*.kts"},
+	"lua":    {"*.lua"},
+	"make":   {"Makefile", "makefile", "GNUmakefile", "*.mk", "*.mak"},
+	"md":     {"*.md", "*.markdown", "*.mdx"},
+	"php":    {"*.php"},
+	"proto":  {"*.proto"},
+	"py":     {"*.py", "*.pyi"},
+	"rb":     {"*.rb", "Gemfile", "Rakefile", "*.gemspec"},
+	"rust":   {"*.rs"},
+	"sh":     {"*.sh", "*.bash", "*.zsh", ".bashrc", ".bash_profile", ".zshrc", ".profile"},
+	"sql":    {"*.sql"},
+	"swift":  {"*.swift"},
+	"toml":   {"*.toml", "Cargo.lock"},
+	"ts":     {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"txt":    {"*.txt"},
+	"xml":    {"*.xml", "*.xsd", "*.xsl", "*.svg"},
+	"yaml":   {"*.yaml", "*.yml"},
+}
+
+// FileTypes returns the file types that Options.Types and
+// Options.ExcludeTypes may name, mapped to the globs matching their file
+// names. They are the built-in types extended by the types defined in the
+// user's types file, $XDG_CONFIG_HOME/vtk/types or ~/.config/vtk/types.
+//
+// Each line of the types file defines a type, or adds globs to an existing
+// one, as a name, a colon and a comma-separated list of globs:
+//
+//	web: *.html, *.css, *.js
+//
+// Blank lines and lines starting with "#" are ignored.
+func FileTypes() (map[string][]string, error) {
+	types := make(map[string][]string, len(builtinFileTypes))
+	for name, globs := range builtinFileTypes {
+		types[name] = append([]string(nil), globs...)
+	}
+
+	path := typesFile()
+	if path == "" {
+		return types, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return types, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file types: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, globList, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t,") {
+			return nil, fmt.Errorf("%s:%d: expected a type definition like \"name: *.ext\"", path, lineNumber)
+		}
+		for _, glob := range strings.Split(globList, ",") {
+			glob = strings.TrimSpace(glob)
+			if glob == "" {
+				continue
+			}
+			if _, err := filepath.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid glob %q: %w", path, lineNumber, glob, err)
+			}
+			types[name] = append(types[name], glob)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file types: %w", err)
+	}
+	return types, nil
+}
+
+// typesFile returns the path of the user's types file.
+func typesFile() string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "vtk", "types")
+}
+
+// resolveFileTypes returns the globs of the named file types.
+func resolveFileTypes(types map[string][]string, names []string) ([]string, error) {
+	var globs []string
+	for _, name := range names {
+		typeGlobs, ok := types[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown file type: %q", name)
+		}
+		globs = append(globs, typeGlobs...)
+	}
+	return globs, nil
+}
+
+// matchesAnyGlob reports whether the base name of path matches any of globs.
+func matchesAnyGlob(path string, globs []string) bool {
+	name := filepath.Base(path)
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
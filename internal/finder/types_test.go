@@ -0,0 +1,163 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTypesFile points XDG_CONFIG_HOME at a directory holding a types file
+// with content.
+func writeTypesFile(t *testing.T, content string) {
+	t.Helper()
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	os.MkdirAll(filepath.Join(config, "vtk"), 0755)
+	if err := os.WriteFile(filepath.Join(config, "vtk", "types"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTypes(t *testing.T) {
+	writeTypesFile(t, "# custom types\n\nweb: *.html, *.css\ngo: go.mod\n")
+
+	types, err := FileTypes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"*.html", "*.css"}; !reflect.DeepEqual(types["web"], want) {
+		t.Errorf("expected web to be %v, got %v", want, types["web"])
+	}
+	if want := []string{"*.go", "go.mod"}; !reflect.DeepEqual(types["go"], want) {
+		t.Errorf("expected go to be extended to %v, got %v", want, types["go"])
+	}
+	if len(builtinFileTypes["go"]) != 1 {
+		t.Errorf("expected the built-in types to be left alone, got %v", builtinFileTypes["go"])
+	}
+}
+
+func TestFileTypes_Invalid(t *testing.T) {
+	for _, content := range []string{"web *.html\n", ": *.html\n", "web: [\n"} {
+		writeTypesFile(t, content)
+		if _, err := FileTypes(); err == nil || !strings.Contains(err.Error(), "types:1:") {
+			t.Errorf("%q: expected an error naming the line, got %v", content, err)
+		}
+	}
+}
+
+func TestFileTypeFilters(t *testing.T) {
+	writeTypesFile(t, "docs: *.md, README\n")
+
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, "web"), 0755)
+	files := map[string]string{
+		"main.go":       "package main\n\nfunc needle() {}\n",
+		"README":        "needle\n",
+		"notes.md":      "needle\n",
+		"web/app.ts":    "export function needle() {}\n",
+		"web/style.css": "needle {}\n",
+		"Makefile":      "needle:\n",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644)
+	}
+
+	relPaths := func(results []Result) string {
+		var paths []string
+		for _, result := range results {
+			rel, _ := filepath.Rel(tempDir, result.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		sort.Strings(paths)
+		return strings.Join(paths, ",")
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		find    string
+		symbols string
+		glob    string
+	}{
+		{
+			name:    "include",
+			opts:    Options{Types: []string{"go", "make"}},
+			find:    "Makefile,main.go",
+			symbols: "main.go",
+			glob:    "Makefile,main.go",
+		},
+		{
+			name:    "exclude",
+			opts:    Options{ExcludeTypes: []string{"docs", "css"}},
+			find:    "Makefile,main.go,web/app.ts",
+			symbols: "main.go,web/app.ts",
+			glob:    "Makefile,main.go,web/app.ts",
+		},
+		{
+			name:    "include and exclude",
+			opts:    Options{Types: []string{"docs", "ts"}, ExcludeTypes: []string{"md"}},
+			find:    "README,web/app.ts",
+			symbols: "web/app.ts",
+			glob:    "README,web/app.ts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := FindWithOptions(tempDir, "needle", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.find {
+				t.Errorf("find: expected %s, got %s", tt.find, got)
+			}
+
+			results, err = FindSymbolsWithOptions(tempDir, "needle", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.symbols {
+				t.Errorf("symbols: expected %s, got %s", tt.symbols, got)
+			}
+
+			results, err = collect(func(emit func(Result) error) error {
+				return GlobFilesStream(t.Context(), tempDir, ".", tt.opts, emit)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.glob {
+				t.Errorf("glob: expected %s, got %s", tt.glob, got)
+			}
+		})
+	}
+
+	if _, err := FindWithOptions(tempDir, "needle", Options{Types: []string{"cobol"}}); err == nil || !strings.Contains(err.Error(), `unknown file type: "cobol"`) {
+		t.Errorf("expected an unknown type error, got %v", err)
+	}
+}
+
+func TestFileTypeFilters_GoRename(t *testing.T) {
+	writeTypesFile(t, "gosrc: lib.go, main.go\n")
+	root := writeGoModule(t)
+
+	// Excluding Go files leaves the Go declarations alone
+	changes, err := PlanReplaceSymbol(root, "Count", "Total", Options{ExcludeTypes: []string{"go"}})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes with -T go, got %d, %v", len(changes), err)
+	}
+
+	// A type leaving out the tests must not leave their references behind
+	_, err = PlanReplaceSymbol(filepath.Join(root, "lib"), "Count", "Total", Options{Types: []string{"gosrc"}})
+	if want := filepath.Join(root, "lib", "lib_test.go"); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected an error naming %s, got %v", want, err)
+	}
+
+	changes, err = PlanReplaceSymbol(filepath.Join(root, "lib"), "Count", "Total", Options{Types: []string{"go"}})
+	if err != nil || len(changes) != 3 {
+		t.Errorf("expected 3 changed files with -t go, got %d, %v", len(changes), err)
+	}
+}
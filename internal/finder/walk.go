@@ -0,0 +1,147 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkTree walks the tree under dir in lexical order, calling fn with the
+// path and info of every file and directory below dir that the walk
+// controls of filter admit, which a nil filter leaves at their defaults:
+//
+//   - Hidden files and directories are skipped unless filter.hidden.
+//   - Symlinks are reported with the info of their target. Symlinked
+//     directories are only walked when filter.followSymlinks, and never
+//     when they lead back to a directory being walked.
+//   - Directories deeper than filter.maxDepth are not walked, and files
+//     larger than filter.maxFileSize are not reported.
+//   - With filter.oneFileSystem, directories on another file system than
+//     dir are skipped.
+//
+// fn may return filepath.SkipDir for a directory to skip its contents; any
+// other error stops the walk and is returned. Entries that cannot be read
+// are skipped. If dir is a file, fn is called with dir alone.
+func walkTree(dir string, filter *fileFilter, fn func(path string, info os.FileInfo) error) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return fn(dir, info)
+	}
+
+	w := &treeWalker{fn: fn}
+	if filter != nil {
+		w.hidden = filter.hidden
+		w.followSymlinks = filter.followSymlinks
+		w.maxDepth = filter.maxDepth
+		w.maxFileSize = filter.maxFileSize
+		if filter.oneFileSystem {
+			w.device, w.oneFileSystem = deviceID(info)
+		}
+	}
+	return w.walk(dir, &walkAncestor{info: info}, 1)
+}
+
+// treeWalker holds the state of a walkTree call.
+type treeWalker struct {
+	fn func(path string, info os.FileInfo) error
+
+	hidden         bool
+	followSymlinks bool
+	maxDepth       int
+	maxFileSize    int64
+	oneFileSystem  bool
+	device         uint64 // device of the walked directory
+}
+
+// walkAncestor is a directory on the path from the walked directory down to
+// the one being read, used to detect symlink loops.
+type walkAncestor struct {
+	info   os.FileInfo
+	parent *walkAncestor
+}
+
+// walk reports the entries of dir, which is at depth-1 below the walked
+// directory, and walks its subdirectories.
+func (w *treeWalker) walk(dir string, ancestors *walkAncestor, depth int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // Skip directories we can't read
+	}
+
+	for _, entry := range entries {
+		if !w.hidden && isHiddenName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		isLink := info.Mode()&os.ModeSymlink != 0
+		if isLink {
+			if info, err = os.Stat(path); err != nil {
+				continue // Broken link
+			}
+			if info.IsDir() && !w.followSymlinks {
+				continue
+			}
+		}
+
+		if !info.IsDir() {
+			if w.maxFileSize > 0 && info.Size() > w.maxFileSize {
+				continue
+			}
+			if err := w.fn(path, info); err != nil {
+				if err == filepath.SkipDir {
+					return nil
+				}
+				return err
+			}
+			continue
+		}
+
+		if w.oneFileSystem {
+			if device, ok := deviceID(info); ok && device != w.device {
+				continue
+			}
+		}
+		if isLink && ancestors.contains(info) {
+			continue
+		}
+
+		if err := w.fn(path, info); err != nil {
+			if err == filepath.SkipDir {
+				continue
+			}
+			return err
+		}
+		if w.maxDepth > 0 && depth >= w.maxDepth {
+			continue
+		}
+		if err := w.walk(path, &walkAncestor{info: info, parent: ancestors}, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains reports whether the directory described by info is the one of a
+// or of one of its parents.
+func (a *walkAncestor) contains(info os.FileInfo) bool {
+	for ; a != nil; a = a.parent {
+		if os.SameFile(a.info, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHiddenName reports whether a file or directory name is hidden, as names
+// starting with a dot are on Unix.
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
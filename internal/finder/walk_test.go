@@ -0,0 +1,211 @@
+package finder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWalkControls(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTree(t, tempDir, map[string]string{
+		"root/main.go":             "package main\n\nfunc needle() {}\n",
+		"root/.env":                "needle=1\n",
+		"root/.config/settings.go": "package config\n\nfunc needle() {}\n",
+		"root/.git/config":         "needle\n",
+		"root/pkg/big.txt":         strings.Repeat("needle\n", 100),
+		"root/pkg/deep/deep.go":    "package deep\n\nfunc needle() {}\n",
+		"outside/linked.go":        "package linked\n\nfunc needle() {}\n",
+		"outside/.hidden.txt":      "needle\n",
+		"outside/notes/a.txt":      "needle\n",
+		"outside/notes/b.txt":      "no match\n",
+	})
+
+	// outside is only reachable through a symlink, and a loop leads from
+	// inside it back to root
+	root := filepath.Join(tempDir, "root")
+	if err := os.Symlink(filepath.Join(tempDir, "outside"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	os.Symlink(root, filepath.Join(tempDir, "outside", "notes", "loop"))
+	os.Symlink(filepath.Join(root, "main.go"), filepath.Join(root, "pkg", "main_link.go"))
+
+	relPaths := func(results []Result) string {
+		seen := make(map[string]bool)
+		var paths []string
+		for _, result := range results {
+			rel, _ := filepath.Rel(root, result.Path)
+			if rel = filepath.ToSlash(rel); !seen[rel] {
+				seen[rel] = true
+				paths = append(paths, rel)
+			}
+		}
+		sort.Strings(paths)
+		return strings.Join(paths, ",")
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		find    string
+		symbols string
+		glob    string
+		dirs    string
+	}{
+		{
+			name:    "defaults",
+			find:    "main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			symbols: "main.go,pkg/deep/deep.go,pkg/main_link.go",
+			glob:    "main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			dirs:    "pkg,pkg/deep",
+		},
+		{
+			name:    "hidden",
+			opts:    Options{Hidden: true},
+			find:    ".config/settings.go,.env,main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			symbols: ".config/settings.go,main.go,pkg/deep/deep.go,pkg/main_link.go",
+			glob:    ".config/settings.go,.env,main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			dirs:    ".config,pkg,pkg/deep",
+		},
+		{
+			name:    "follow symlinks",
+			opts:    Options{FollowSymlinks: true},
+			find:    "link/linked.go,link/notes/a.txt,main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			symbols: "link/linked.go,main.go,pkg/deep/deep.go,pkg/main_link.go",
+			glob:    "link/linked.go,link/notes/a.txt,link/notes/b.txt,main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			dirs:    "link,link/notes,pkg,pkg/deep",
+		},
+		{
+			name:    "max depth",
+			opts:    Options{MaxDepth: 2, FollowSymlinks: true},
+			find:    "link/linked.go,main.go,pkg/big.txt,pkg/main_link.go",
+			symbols: "link/linked.go,main.go,pkg/main_link.go",
+			glob:    "link/linked.go,main.go,pkg/big.txt,pkg/main_link.go",
+			dirs:    "link,link/notes,pkg,pkg/deep",
+		},
+		{
+			name:    "max file size",
+			opts:    Options{MaxFileSize: 100},
+			find:    "main.go,pkg/deep/deep.go,pkg/main_link.go",
+			symbols: "main.go,pkg/deep/deep.go,pkg/main_link.go",
+			glob:    "main.go,pkg/deep/deep.go,pkg/main_link.go",
+			dirs:    "pkg,pkg/deep",
+		},
+		{
+			name:    "one file system",
+			opts:    Options{OneFileSystem: true},
+			find:    "main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			symbols: "main.go,pkg/deep/deep.go,pkg/main_link.go",
+			glob:    "main.go,pkg/big.txt,pkg/deep/deep.go,pkg/main_link.go",
+			dirs:    "pkg,pkg/deep",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := FindWithOptions(root, "needle", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.find {
+				t.Errorf("find: expected %s, got %s", tt.find, got)
+			}
+
+			results, err = FindSymbolsWithOptions(root, "needle", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.symbols {
+				t.Errorf("symbols: expected %s, got %s", tt.symbols, got)
+			}
+
+			results, err = collect(func(emit func(Result) error) error {
+				return GlobFilesStream(t.Context(), root, ".", tt.opts, emit)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.glob {
+				t.Errorf("glob: expected %s, got %s", tt.glob, got)
+			}
+
+			results, err = collect(func(emit func(Result) error) error {
+				return GlobDirectoriesStream(t.Context(), root, ".", tt.opts, emit)
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(results); got != tt.dirs {
+				t.Errorf("dirs: expected %s, got %s", tt.dirs, got)
+			}
+
+			changes, err := PlanReplace(root, "needle", "pin", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var changed []Result
+			for _, change := range changes {
+				changed = append(changed, Result{Path: change.Path})
+			}
+			if got := relPaths(changed); got != tt.find {
+				t.Errorf("replace: expected %s, got %s", tt.find, got)
+			}
+		})
+	}
+}
+
+func TestWalkTree_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".hidden.txt")
+	os.WriteFile(path, []byte("needle\n"), 0644)
+
+	// A hidden file named directly is still searched
+	results, err := Find(path, "needle")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != path {
+		t.Errorf("expected a match in %s, got %+v", path, results)
+	}
+}
+
+func TestWalkControls_GoRename(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"go.mod":             "module example.com/m\n\ngo 1.24\n",
+		"top.go":             "package m\n\nfunc Top() int { return 1 }\n",
+		"deep/pkg/p.go":      "package pkg\n\nimport \"example.com/m\"\n\nfunc Old() int { return m.Top() }\n",
+		"deep/pkg/p_test.go": "package pkg\n\nvar _ = Old()\n",
+		"deep/pkg/big.go":    "package pkg\n\n// " + strings.Repeat("x", 200) + "\nfunc Big() int { return Old() }\n",
+	})
+
+	paths := func(changes []Change) string {
+		var rels []string
+		for _, change := range changes {
+			rel, _ := filepath.Rel(root, change.Path)
+			rels = append(rels, filepath.ToSlash(rel))
+		}
+		return strings.Join(rels, ",")
+	}
+
+	// Declarations beyond the walk are not renamed
+	changes, err := PlanReplaceSymbol(root, "Old", "New", Options{MaxDepth: 1})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes with a max depth of 1, got %s, %v", paths(changes), err)
+	}
+	changes, err = PlanReplaceSymbol(root, "Old", "New", Options{MaxDepth: 3})
+	if got, want := paths(changes), "deep/pkg/big.go,deep/pkg/p.go,deep/pkg/p_test.go"; err != nil || got != want {
+		t.Errorf("expected %s with a max depth of 3, got %s, %v", want, got, err)
+	}
+
+	// References are renamed wherever they are, so that the tree compiles
+	changes, err = PlanReplaceSymbol(root, "Top", "Peak", Options{MaxDepth: 1, MaxFileSize: 100})
+	if got, want := paths(changes), "deep/pkg/p.go,top.go"; err != nil || got != want {
+		t.Errorf("expected %s, got %s, %v", want, got, err)
+	}
+	changes, err = PlanReplaceSymbol(root, "Big", "Huge", Options{MaxFileSize: 100})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes to a file over the size limit, got %s, %v", paths(changes), err)
+	}
+}
@@ -12,42 +12,62 @@ import (
 // JSON formats JSON data with pretty printing.
 // It takes raw JSON bytes and outputs formatted JSON to stdout.
 func JSON(data []byte) error {
+	prettyJSON, err := PrettyJSON(data)
+	if err != nil {
+		return err
+	}
+
+	// Output to stdout
+	fmt.Println(prettyJSON)
+	return nil
+}
+
+// PrettyJSON returns JSON data pretty printed with two-space indentation,
+// without a trailing newline.
+func PrettyJSON(data []byte) (string, error) {
 	// Parse JSON
 	var jsonData interface{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
 	// Pretty print JSON
 	prettyJSON, err := json.MarshalIndent(jsonData, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to format JSON: %w", err)
+		return "", fmt.Errorf("failed to format JSON: %w", err)
 	}
-
-	// Output to stdout
-	fmt.Println(string(prettyJSON))
-	return nil
+	return string(prettyJSON), nil
 }
 
 // SQL formats SQL statements with proper indentation.
 // It takes raw SQL bytes and outputs formatted SQL to stdout.
 func SQL(data []byte) error {
+	formatted, err := PrettySQL(data)
+	if err != nil {
+		return err
+	}
+
+	// Output to stdout
+	fmt.Println(formatted)
+	return nil
+}
+
+// PrettySQL returns SQL statements formatted with proper indentation,
+// without a trailing newline.
+func PrettySQL(data []byte) (string, error) {
 	// Trim whitespace
 	sql := strings.TrimSpace(string(data))
 
 	// Check for empty input
 	if sql == "" {
-		return fmt.Errorf("failed to parse SQL: empty input")
+		return "", fmt.Errorf("failed to parse SQL: empty input")
 	}
 
 	// Format SQL using go-sqlfmt
 	formatter := &sqlfmt.Formatter{}
 	formatted, err := formatter.Format(sql)
 	if err != nil {
-		return fmt.Errorf("failed to parse SQL: %w", err)
+		return "", fmt.Errorf("failed to parse SQL: %w", err)
 	}
-
-	// Output to stdout
-	fmt.Println(formatted)
-	return nil
+	return formatted, nil
 }
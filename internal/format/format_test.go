@@ -304,3 +304,17 @@ func TestSQL_ErrorCases(t *testing.T) {
 		})
 	}
 }
+
+func TestPrettyJSON(t *testing.T) {
+	got, err := PrettyJSON([]byte(`{"b":[1],"a":null}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "{\n  \"a\": null,\n  \"b\": [\n    1\n  ]\n}"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if _, err := PrettySQL([]byte("  ")); err == nil {
+		t.Error("expected an error for empty SQL")
+	}
+}